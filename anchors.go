@@ -0,0 +1,38 @@
+package main
+
+import (
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/pkg/errors"
+)
+
+// checkYAMLAliases returns an error if the yaml document uses any aliases.
+// The document is only parsed into nodes so aliases are never expanded,
+// which protects against alias bombs (billion laughs) in untrusted files.
+func checkYAMLAliases(file string, byt []byte) error {
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(byt, &doc); err != nil {
+		return errors.Wrapf(err, "failed to parse values file %s as yaml", file)
+	}
+
+	if alias := findYAMLAlias(&doc); alias != nil {
+		return errors.Errorf("values file %s uses yaml alias *%s at line %d, col %d which is not allowed with --no-yaml-anchors",
+			file, alias.Value, alias.Line, alias.Column)
+	}
+
+	return nil
+}
+
+func findYAMLAlias(node *yamlv3.Node) *yamlv3.Node {
+	if node.Kind == yamlv3.AliasNode {
+		return node
+	}
+
+	for _, child := range node.Content {
+		if alias := findYAMLAlias(child); alias != nil {
+			return alias
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const ssmScheme = "ssm://"
+
+// readSSMParameters fetches all SSM parameters below prefix with the aws
+// cli, which takes care of credentials, region and pagination. The
+// parameters are nested by the segments of their names after prefix.
+// StringList parameters become lists.
+func readSSMParameters(prefix string) (map[string]interface{}, error) {
+	prefix = "/" + strings.Trim(prefix, "/")
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.Command("aws", "ssm", "get-parameters-by-path",
+		"--path", prefix, "--recursive", "--with-decryption", "--output", "json")
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); len(msg) != 0 {
+			return nil, errors.Errorf("failed to get ssm parameters under %s: %s", prefix, msg)
+		}
+		return nil, errors.Wrapf(err, "failed to get ssm parameters under %s", prefix)
+	}
+
+	var result struct {
+		Parameters []struct {
+			Name  string
+			Type  string
+			Value string
+		}
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse ssm parameters under %s", prefix)
+	}
+
+	data := map[string]interface{}{}
+	for _, param := range result.Parameters {
+		name := strings.Trim(strings.TrimPrefix(param.Name, prefix), "/")
+		if len(name) == 0 {
+			continue
+		}
+
+		var value interface{} = param.Value
+		if param.Type == "StringList" {
+			var list []interface{}
+			for _, elem := range strings.Split(param.Value, ",") {
+				list = append(list, elem)
+			}
+			value = list
+		}
+
+		segments := strings.Split(name, "/")
+		m := data
+		for _, segment := range segments[:len(segments)-1] {
+			next, ok := m[segment].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				m[segment] = next
+			}
+			m = next
+		}
+		m[segments[len(segments)-1]] = value
+	}
+
+	return data, nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// bundleTemplate is the name of the template inside a --bundle archive.
+const bundleTemplate = "template.tpl"
+
+// bundle is an archive extracted to a temporary directory. The template is
+// the shallowest template.tpl in the archive and the values files are the
+// json and yaml files beside or below it, in lexical order.
+type bundle struct {
+	dir      string
+	template string
+	values   []string
+}
+
+// openBundle extracts a .tar, .tar.gz, .tgz or .zip archive. Close must be
+// called to remove the extracted files.
+func openBundle(archive string) (*bundle, error) {
+	dir, err := ioutil.TempDir("", "txtplate-bundle")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create bundle directory")
+	}
+	b := &bundle{dir: dir}
+
+	switch {
+	case strings.HasSuffix(archive, ".zip"):
+		err = extractZip(archive, dir)
+	case strings.HasSuffix(archive, ".tar.gz"), strings.HasSuffix(archive, ".tgz"):
+		err = extractTar(archive, dir, true)
+	case strings.HasSuffix(archive, ".tar"):
+		err = extractTar(archive, dir, false)
+	default:
+		err = errors.New("unknown archive type, expected .tar, .tar.gz, .tgz or .zip")
+	}
+	if err != nil {
+		b.Close()
+		return nil, errors.Wrapf(err, "failed to extract bundle %s", archive)
+	}
+
+	if err = b.find(); err != nil {
+		b.Close()
+		return nil, errors.Wrapf(err, "bundle %s", archive)
+	}
+
+	return b, nil
+}
+
+// Close removes the extracted files.
+func (b *bundle) Close() error {
+	return os.RemoveAll(b.dir)
+}
+
+func (b *bundle) find() error {
+	var templates []string
+	err := filepath.Walk(b.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && info.Name() == bundleTemplate {
+			templates = append(templates, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(templates) == 0 {
+		return errors.Errorf("no %s found", bundleTemplate)
+	}
+
+	depth := func(path string) int { return strings.Count(path, string(filepath.Separator)) }
+	sort.SliceStable(templates, func(i, j int) bool { return depth(templates[i]) < depth(templates[j]) })
+	if len(templates) > 1 && depth(templates[0]) == depth(templates[1]) {
+		return errors.Errorf("more than one %s found at the top level", bundleTemplate)
+	}
+	b.template = templates[0]
+
+	return filepath.Walk(filepath.Dir(b.template), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".json", ".yaml", ".yml":
+			b.values = append(b.values, path)
+		}
+		return nil
+	})
+}
+
+// bundlePath returns where name should be extracted to inside dir, entries
+// that would end up outside of it are an error.
+func bundlePath(dir, name string) (string, error) {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if rel, err := filepath.Rel(dir, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errors.Errorf("entry %q is outside of the archive", name)
+	}
+	return path, nil
+}
+
+func extractTar(archive, dir string, gzipped bool) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if gzipped {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		// Only regular files are extracted, directories are created as
+		// needed and links are ignored
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+
+		path, err := bundlePath(dir, hdr.Name)
+		if err != nil {
+			return err
+		}
+		if err = extractFile(path, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func extractZip(archive, dir string) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, zf := range zr.File {
+		if !zf.Mode().IsRegular() {
+			continue
+		}
+
+		path, err := bundlePath(dir, zf.Name)
+		if err != nil {
+			return err
+		}
+
+		r, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		err = extractFile(path, r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func extractFile(path string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/pkg/errors"
+)
+
+// canonicalJSON re-emits each json document in byt with sorted keys and
+// two space indentation. Numbers are kept as they were written.
+func canonicalJSON(byt []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(byt))
+	dec.UseNumber()
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+
+	for docs := 0; ; docs++ {
+		var value interface{}
+		if err := dec.Decode(&value); err == io.EOF && docs != 0 {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "failed to parse json")
+		}
+
+		if err := enc.Encode(value); err != nil {
+			return nil, errors.Wrap(err, "failed to marshal json")
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// canonicalYAML re-emits each yaml document in byt with sorted keys, two
+// space indentation and the default scalar styles. yaml.v2 can only read a
+// single document so yaml.v3 splits them up first.
+func canonicalYAML(byt []byte) ([]byte, error) {
+	dec := yamlv3.NewDecoder(bytes.NewReader(byt))
+
+	buf := &bytes.Buffer{}
+	for docs := 0; ; docs++ {
+		var doc yamlv3.Node
+		if err := dec.Decode(&doc); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "failed to parse yaml")
+		}
+
+		docBytes, err := yamlv3.Marshal(&doc)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to split yaml documents")
+		}
+
+		var value interface{}
+		if err = yaml.Unmarshal(docBytes, &value); err != nil {
+			return nil, errors.Wrap(err, "failed to parse yaml")
+		}
+
+		out, err := yaml.Marshal(value)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal yaml")
+		}
+
+		if docs != 0 {
+			buf.WriteString("---\n")
+		}
+		buf.Write(out)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// canonicalize re-emits byt in the canonical form of format.
+func canonicalize(format string, byt []byte) ([]byte, error) {
+	switch format {
+	case "json":
+		return canonicalJSON(byt)
+	case "yaml", "yml":
+		return canonicalYAML(byt)
+	default:
+		return nil, errors.Errorf("unknown canonical format %q", format)
+	}
+}
+
+// canonicalJSONFunc is the canonicalJson template function.
+func canonicalJSONFunc(str string) (string, error) {
+	out, err := canonicalJSON([]byte(str))
+	if err != nil {
+		return "", errors.Wrap(err, "canonicalJson")
+	}
+
+	return string(bytes.TrimSuffix(out, []byte("\n"))), nil
+}
+
+// canonicalYAMLFunc is the canonicalYaml template function.
+func canonicalYAMLFunc(str string) (string, error) {
+	out, err := canonicalYAML([]byte(str))
+	if err != nil {
+		return "", errors.Wrap(err, "canonicalYaml")
+	}
+
+	return string(bytes.TrimSuffix(out, []byte("\n"))), nil
+}
+
+// toYamlList renders each element of list as a yaml list item ("- ")
+// with every line indented by indent spaces. Maps and lists inside of the
+// elements are rendered as nested yaml under their "- ".
+func toYamlList(list interface{}, indent int) (string, error) {
+	v := reflect.ValueOf(list)
+	if list == nil || (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) {
+		return "", errors.Errorf("toYamlList: expected a list, got %T", list)
+	}
+	if indent < 0 {
+		return "", errors.Errorf("toYamlList: indent must not be negative, got %d", indent)
+	}
+
+	pad := strings.Repeat(" ", indent)
+	lines := make([]string, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		byt, err := yaml.Marshal(v.Index(i).Interface())
+		if err != nil {
+			return "", errors.Wrapf(err, "toYamlList: failed to marshal element %d", i)
+		}
+
+		for j, line := range strings.Split(strings.TrimSuffix(string(byt), "\n"), "\n") {
+			if j == 0 {
+				lines = append(lines, pad+"- "+line)
+			} else {
+				lines = append(lines, pad+"  "+line)
+			}
+		}
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
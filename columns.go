@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// parseColumns splits whitespace delimited lines into records keyed by the
+// headers on the first line. See parseColumnsWith.
+func parseColumns(str string) []map[string]interface{} {
+	return parseTable(str, "", strings.Fields)
+}
+
+// parseColumnsWith splits lines on sep into records keyed by the headers on
+// the first line. Cells are trimmed and blank lines skipped. Rows that are
+// short of cells get empty strings for the rest, cells past the last header
+// are joined back onto the last column.
+func parseColumnsWith(sep, str string) ([]map[string]interface{}, error) {
+	if len(sep) == 0 {
+		return nil, errors.New("parseColumnsWith: separator must not be empty")
+	}
+
+	return parseTable(str, sep, func(line string) []string {
+		cells := strings.Split(line, sep)
+		for i, cell := range cells {
+			cells[i] = strings.TrimSpace(cell)
+		}
+		return cells
+	}), nil
+}
+
+func parseTable(str, sep string, split func(string) []string) []map[string]interface{} {
+	var headers []string
+	records := []map[string]interface{}{}
+
+	for _, line := range strings.Split(str, "\n") {
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		cells := split(line)
+		if headers == nil {
+			headers = cells
+			continue
+		}
+
+		if len(cells) > len(headers) {
+			join := sep
+			if len(join) == 0 {
+				join = " "
+			}
+			last := len(headers) - 1
+			cells = append(cells[:last], strings.Join(cells[last:], join))
+		}
+
+		record := make(map[string]interface{}, len(headers))
+		for i, header := range headers {
+			if i < len(cells) {
+				record[header] = cells[i]
+			} else {
+				record[header] = ""
+			}
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
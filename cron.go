@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	cronEveryN  = regexp.MustCompile(`^every (\d+) (minute|hour)s?$`)
+	cronAt      = regexp.MustCompile(`^(.+?) at (.+)$`)
+	cronClock   = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?\s*(am|pm)?$`)
+	cronWeekday = map[string]string{
+		"sunday": "0", "monday": "1", "tuesday": "2", "wednesday": "3",
+		"thursday": "4", "friday": "5", "saturday": "6",
+		"weekday": "1-5", "weekend": "0,6",
+	}
+)
+
+// cron turns a human schedule into a standard five field cron expression.
+// It understands "every minute", "every N minutes", "every hour", "every N
+// hours" (N must divide 60 or 24 evenly), "hourly", "daily", "weekly",
+// "monthly", "yearly", "every day", "every monday" (any day name, weekday
+// or weekend) and the day based ones followed by "at 14:30", "at 9am", "at
+// noon" or "at midnight". A valid cron expression is returned as it is.
+func cron(phrase string) (string, error) {
+	phrase = strings.ToLower(strings.Join(strings.Fields(phrase), " "))
+	if _, err := parseCron(phrase); err == nil {
+		return phrase, nil
+	}
+
+	if m := cronEveryN.FindStringSubmatch(phrase); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		period := 60
+		if m[2] == "hour" {
+			period = 24
+		}
+		if n < 1 || n >= period {
+			return "", errors.Errorf("cron: every %d %ss is out of range 1-%d", n, m[2], period-1)
+		}
+		// */n restarts at the top of each hour or day, so anything else
+		// would have one short gap
+		if period%n != 0 {
+			return "", errors.Errorf("cron: every %d %ss can't be written as cron, it must divide %d evenly", n, m[2], period)
+		}
+		if m[2] == "minute" {
+			return fmt.Sprintf("*/%d * * * *", n), nil
+		}
+		return fmt.Sprintf("0 */%d * * *", n), nil
+	}
+
+	switch phrase {
+	case "every minute":
+		return "* * * * *", nil
+	case "every hour", "hourly":
+		return "0 * * * *", nil
+	}
+
+	minute, hour := "0", "0"
+	day := phrase
+	if m := cronAt.FindStringSubmatch(phrase); m != nil {
+		var err error
+		if minute, hour, err = cronTime(m[2]); err != nil {
+			return "", err
+		}
+		day = m[1]
+	}
+
+	day = strings.TrimPrefix(day, "every ")
+	switch day {
+	case "day", "daily":
+		return fmt.Sprintf("%s %s * * *", minute, hour), nil
+	case "week", "weekly":
+		return fmt.Sprintf("%s %s * * 0", minute, hour), nil
+	case "month", "monthly":
+		return fmt.Sprintf("%s %s 1 * *", minute, hour), nil
+	case "year", "yearly", "annually":
+		return fmt.Sprintf("%s %s 1 1 *", minute, hour), nil
+	}
+	if dow, ok := cronWeekday[day]; ok {
+		return fmt.Sprintf("%s %s * * %s", minute, hour, dow), nil
+	}
+
+	return "", errors.Errorf("cron: cannot understand schedule %q", phrase)
+}
+
+// cronTime parses the time of day after "at" into minute and hour fields.
+func cronTime(clock string) (string, string, error) {
+	switch clock {
+	case "noon":
+		return "0", "12", nil
+	case "midnight":
+		return "0", "0", nil
+	}
+
+	m := cronClock.FindStringSubmatch(clock)
+	if m == nil {
+		return "", "", errors.Errorf("cron: cannot understand time %q", clock)
+	}
+
+	hour, _ := strconv.Atoi(m[1])
+	minute := 0
+	if len(m[2]) != 0 {
+		minute, _ = strconv.Atoi(m[2])
+	}
+
+	switch m[3] {
+	case "am", "pm":
+		if hour < 1 || hour > 12 {
+			return "", "", errors.Errorf("cron: hour in %q must be 1-12", clock)
+		}
+		hour %= 12
+		if m[3] == "pm" {
+			hour += 12
+		}
+	}
+	if hour > 23 || minute > 59 {
+		return "", "", errors.Errorf("cron: %q is not a valid time", clock)
+	}
+
+	return strconv.Itoa(minute), strconv.Itoa(hour), nil
+}
+
+// cronNext returns the next time after now that expr fires, in local time.
+func cronNext(expr string) (time.Time, error) {
+	sched, err := parseCron(expr)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "cronNext")
+	}
+
+	next, ok := sched.next(time.Now())
+	if !ok {
+		return time.Time{}, errors.Errorf("cronNext: %q never fires", expr)
+	}
+	return next, nil
+}
+
+// cronSchedule holds a bit per allowed value of each cron field.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar are true when the field was *, cron matches a
+	// day when either day field matches unless one of them is a *.
+	domStar, dowStar bool
+}
+
+type cronField struct {
+	min, max int
+	names    []string
+}
+
+var cronFields = []cronField{
+	{min: 0, max: 59},
+	{min: 0, max: 23},
+	{min: 1, max: 31},
+	{min: 1, max: 12, names: []string{"", "jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// parseCron parses a five field cron expression. Fields may be *, numbers,
+// ranges, steps (*/5, 1-10/2), comma separated lists and for the month and
+// day of week fields three letter names.
+func parseCron(expr string) (cronSchedule, error) {
+	var sched cronSchedule
+
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return sched, errors.Errorf("cron expression %q must have 5 fields", expr)
+	}
+
+	bits := make([]uint64, len(fields))
+	for i, field := range fields {
+		var err error
+		if bits[i], err = parseCronField(field, cronFields[i]); err != nil {
+			return sched, errors.Wrapf(err, "cron expression %q", expr)
+		}
+	}
+
+	sched.minute, sched.hour, sched.dom, sched.month, sched.dow = bits[0], bits[1], bits[2], bits[3], bits[4]
+	// 7 is also sunday
+	if sched.dow&(1<<7) != 0 {
+		sched.dow |= 1
+	}
+	sched.domStar = fields[2] == "*"
+	sched.dowStar = fields[4] == "*"
+
+	return sched, nil
+}
+
+func parseCronField(field string, spec cronField) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if idx := strings.IndexByte(part, '/'); idx >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[idx+1:]); err != nil || step < 1 {
+				return 0, errors.Errorf("invalid step in %q", part)
+			}
+			rng = part[:idx]
+		}
+
+		lo, hi := spec.min, spec.max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = cronValue(bounds[0], spec); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = cronValue(bounds[1], spec); err != nil {
+					return 0, err
+				}
+			} else if step != 1 {
+				hi = spec.max
+			}
+			if hi < lo {
+				return 0, errors.Errorf("invalid range %q", rng)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func cronValue(str string, spec cronField) (int, error) {
+	for i, name := range spec.names {
+		if len(name) != 0 && strings.EqualFold(str, name) {
+			return i, nil
+		}
+	}
+
+	v, err := strconv.Atoi(str)
+	if err != nil || v < spec.min || v > spec.max {
+		return 0, errors.Errorf("%q must be between %d and %d", str, spec.min, spec.max)
+	}
+	return v, nil
+}
+
+func (s cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the first minute after from that matches. It gives up after
+// five years so impossible dates like 30 feb don't loop forever.
+func (s cronSchedule) next(from time.Time) (time.Time, bool) {
+	t := from.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// addDuration returns t moved forward by the duration d, d is in the form
+// accepted by time.ParseDuration (eg. 24h, 90m). t may be a time.Time or an
+// RFC3339 string.
+func addDuration(d string, t interface{}) (time.Time, error) {
+	return shiftTime("addDuration", d, t, 1)
+}
+
+// subDuration returns t moved backward by the duration d, see addDuration.
+func subDuration(d string, t interface{}) (time.Time, error) {
+	return shiftTime("subDuration", d, t, -1)
+}
+
+func shiftTime(fn, d string, t interface{}, sign time.Duration) (time.Time, error) {
+	duration, err := time.ParseDuration(d)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "%s: failed to parse duration", fn)
+	}
+
+	tm, err := toTime(fn, t)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return tm.Add(sign * duration), nil
+}
+
+// toRFC3339 formats t (a time.Time or RFC3339 string) as RFC3339.
+func toRFC3339(t interface{}) (string, error) {
+	tm, err := toTime("toRFC3339", t)
+	if err != nil {
+		return "", err
+	}
+
+	return tm.Format(time.RFC3339), nil
+}
+
+var humanizeUnits = []struct {
+	name string
+	size time.Duration
+}{
+	{"year", 365 * 24 * time.Hour},
+	{"month", 30 * 24 * time.Hour},
+	{"day", 24 * time.Hour},
+	{"hour", time.Hour},
+	{"minute", time.Minute},
+	{"second", time.Second},
+}
+
+// humanizeTime describes t (a time.Time or RFC3339 string) relative to
+// now, eg. "in 3 days" or "2 hours ago".
+func humanizeTime(t interface{}) (string, error) {
+	tm, err := toTime("humanizeTime", t)
+	if err != nil {
+		return "", err
+	}
+
+	diff := time.Until(tm)
+	abs := diff
+	if abs < 0 {
+		abs = -abs
+	}
+	if abs < time.Second {
+		return "now", nil
+	}
+
+	var amount string
+	for _, unit := range humanizeUnits {
+		if abs < unit.size {
+			continue
+		}
+
+		n := int(math.Round(float64(abs) / float64(unit.size)))
+		if n == 1 {
+			amount = fmt.Sprintf("1 %s", unit.name)
+		} else {
+			amount = fmt.Sprintf("%d %ss", n, unit.name)
+		}
+		break
+	}
+
+	if diff < 0 {
+		return amount + " ago", nil
+	}
+	return "in " + amount, nil
+}
+
+// toTime accepts either a time.Time or an RFC3339 formatted string.
+func toTime(fn string, t interface{}) (time.Time, error) {
+	switch v := t.(type) {
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		return *v, nil
+	case string:
+		tm, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return time.Time{}, errors.Wrapf(err, "%s: failed to parse time", fn)
+		}
+		return tm, nil
+	default:
+		return time.Time{}, errors.Errorf("%s: expected a time or RFC3339 string, got %T", fn, t)
+	}
+}
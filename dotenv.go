@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// dotenvFuncs returns the toDotenv template function. When upper is true
+// keys are converted to UPPER_SNAKE case, otherwise they are joined with
+// underscores but left as they are.
+func dotenvFuncs(upper bool) template.FuncMap {
+	return template.FuncMap{
+		"toDotenv": func(value interface{}) (string, error) {
+			return toDotenv(value, upper)
+		},
+	}
+}
+
+// toDotenv flattens a map into KEY=value lines sorted by key. Nested map
+// keys and list indexes are joined to their parent key with an underscore.
+func toDotenv(value interface{}, upper bool) (string, error) {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return "", errors.Errorf("toDotenv: expected a map, got %T", value)
+	}
+
+	vars := map[string]string{}
+	flattenDotenv(vars, "", m, upper)
+
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf := &strings.Builder{}
+	for _, k := range keys {
+		fmt.Fprintf(buf, "%s=%s\n", k, dotenvQuote(vars[k]))
+	}
+
+	return buf.String(), nil
+}
+
+func flattenDotenv(vars map[string]string, prefix string, value interface{}, upper bool) {
+	join := func(key string) string {
+		if upper {
+			key = dotenvKey(key)
+		}
+		if len(prefix) == 0 {
+			return key
+		}
+		return prefix + "_" + key
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, elem := range v {
+			flattenDotenv(vars, join(k), elem, upper)
+		}
+	case []interface{}:
+		for i, elem := range v {
+			flattenDotenv(vars, join(strconv.Itoa(i)), elem, upper)
+		}
+	case nil:
+		vars[prefix] = ""
+	default:
+		vars[prefix] = fmt.Sprint(v)
+	}
+}
+
+// dotenvKey converts a key to UPPER_SNAKE case, camelCase humps and any
+// character that isn't a letter or digit become underscores.
+func dotenvKey(key string) string {
+	runes := []rune(key)
+	buf := &strings.Builder{}
+	for i, r := range runes {
+		switch {
+		case unicode.IsUpper(r) && i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])):
+			buf.WriteByte('_')
+			buf.WriteRune(r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			buf.WriteRune(unicode.ToUpper(r))
+		default:
+			buf.WriteByte('_')
+		}
+	}
+
+	return buf.String()
+}
+
+// dotenvQuote double quotes a value if it contains anything other than
+// characters that are safe unquoted in a dotenv file.
+func dotenvQuote(value string) string {
+	safe := true
+	for _, r := range value {
+		if !(unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-.,/:@%+", r)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return value
+	}
+
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`", "\n", `\n`)
+	return `"` + replacer.Replace(value) + `"`
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+
+	"github.com/pkg/errors"
+)
+
+// lookupEncoding finds a character encoding by its IANA name or alias
+// (eg. latin1, ISO-8859-1, UTF-16LE) falling back to the WHATWG names
+// used by browsers (eg. windows-1252, shift_jis).
+func lookupEncoding(name string) (encoding.Encoding, error) {
+	enc, err := ianaindex.IANA.Encoding(name)
+	if err == nil && enc != nil {
+		return enc, nil
+	}
+
+	if enc, err = htmlindex.Get(name); err == nil {
+		return enc, nil
+	}
+
+	return nil, errors.Errorf("unknown or unsupported encoding %q", name)
+}
+
+// decodeToUTF8 transcodes byt from the named encoding to utf-8. A byte
+// order mark at the start of the input overrides the encoding given.
+func decodeToUTF8(name string, byt []byte) ([]byte, error) {
+	enc, err := lookupEncoding(name)
+	if err != nil {
+		return nil, err
+	}
+
+	out, _, err := transform.Bytes(unicode.BOMOverride(enc.NewDecoder()), byt)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode %s", name)
+	}
+
+	return out, nil
+}
+
+// encodeFromUTF8 transcodes utf-8 byt to the named encoding.
+func encodeFromUTF8(name string, byt []byte) ([]byte, error) {
+	enc, err := lookupEncoding(name)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := enc.NewEncoder().Bytes(byt)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to encode %s", name)
+	}
+
+	return out, nil
+}
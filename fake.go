@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	fakeFirstNames = []string{
+		"Alice", "Bob", "Carol", "Dave", "Erin", "Frank", "Grace", "Heidi",
+		"Ivan", "Judy", "Mallory", "Niaj", "Olivia", "Peggy", "Rupert", "Sybil",
+		"Trent", "Uma", "Victor", "Walter", "Xena", "Yusuf", "Zoe",
+	}
+	fakeLastNames = []string{
+		"Anderson", "Brown", "Chen", "Davis", "Evans", "Garcia", "Hughes",
+		"Ito", "Johnson", "Kim", "Lopez", "Miller", "Nguyen", "Okafor", "Patel",
+		"Quinn", "Rossi", "Smith", "Taylor", "Walker", "Young",
+	}
+	fakeWords = []string{
+		"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf",
+		"hotel", "india", "juliet", "kilo", "lima", "mike", "november", "oscar",
+		"papa", "quebec", "romeo", "sierra", "tango", "uniform", "victor",
+		"whiskey", "xray", "yankee", "zulu",
+	}
+	fakeDomains = []string{"example.com", "example.net", "example.org"}
+)
+
+// faker generates random test fixture data. With the same seed and the
+// same calls in the same order it produces the same data.
+type faker struct {
+	rand *rand.Rand
+}
+
+// fakeFuncs returns the fake data template functions. When allowed is false
+// the functions are still defined but fail so that templates don't produce
+// random output by accident.
+func fakeFuncs(allowed bool, seed int64) template.FuncMap {
+	names := []string{"fakeFirstName", "fakeLastName", "fakeName", "fakeEmail", "fakeWord", "fakeInt"}
+
+	if !allowed {
+		funcs := template.FuncMap{}
+		for _, name := range names {
+			name := name
+			funcs[name] = func(...interface{}) (string, error) {
+				return "", errors.Errorf("%s: fake data functions require --allow-fake", name)
+			}
+		}
+		return funcs
+	}
+
+	f := &faker{rand: rand.New(rand.NewSource(seed))}
+	return template.FuncMap{
+		"fakeFirstName": f.firstName,
+		"fakeLastName":  f.lastName,
+		"fakeName":      f.name,
+		"fakeEmail":     f.email,
+		"fakeWord":      f.word,
+		"fakeInt":       f.int,
+	}
+}
+
+func (f *faker) pick(list []string) string {
+	return list[f.rand.Intn(len(list))]
+}
+
+func (f *faker) firstName() string { return f.pick(fakeFirstNames) }
+func (f *faker) lastName() string  { return f.pick(fakeLastNames) }
+func (f *faker) word() string      { return f.pick(fakeWords) }
+
+func (f *faker) name() string {
+	return f.firstName() + " " + f.lastName()
+}
+
+func (f *faker) email() string {
+	return fmt.Sprintf("%s.%s@%s",
+		strings.ToLower(f.firstName()), strings.ToLower(f.lastName()), f.pick(fakeDomains))
+}
+
+// int returns a random integer in [min, max].
+func (f *faker) int(min, max int) (int, error) {
+	if max < min {
+		return 0, errors.Errorf("fakeInt: max %d is less than min %d", max, min)
+	}
+
+	return min + f.rand.Intn(max-min+1), nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"go/format"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// formatters maps an output file extension (including the dot) to the
+// formatter to run over output with that extension. The built-in
+// formatters are gofmt, json and yaml, anything else is run as a command
+// that reads the output on stdin and writes the formatted output to stdout.
+type formatters map[string]string
+
+var formatterSep = regexp.MustCompile(`,\.[a-zA-Z0-9_+-]+:`)
+
+// parseFormatters parses .ext:formatter pairs given to --format-by-ext.
+// Each flag may hold several pairs separated by commas. A comma only
+// separates pairs when it is followed by another .ext: so commands like
+// jq '[.a,.b]' are left alone.
+func parseFormatters(flags []string) (formatters, error) {
+	var specs []string
+	for _, flag := range flags {
+		start := 0
+		for _, idx := range formatterSep.FindAllStringIndex(flag, -1) {
+			specs = append(specs, flag[start:idx[0]])
+			start = idx[0] + 1
+		}
+		specs = append(specs, flag[start:])
+	}
+
+	f := make(formatters, len(specs))
+	for _, spec := range specs {
+		idx := strings.IndexByte(spec, ':')
+		if idx <= 0 {
+			return nil, errors.Errorf("format %q must be in the form .ext:formatter", spec)
+		}
+
+		formatter := strings.TrimSpace(spec[idx+1:])
+		if len(formatter) == 0 {
+			return nil, errors.Errorf("format %q must be in the form .ext:formatter", spec)
+		}
+
+		ext := spec[:idx]
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		f[ext] = formatter
+	}
+
+	return f, nil
+}
+
+// format runs the formatter for file's extension over byt, if there is one.
+func (f formatters) format(file string, byt []byte) ([]byte, error) {
+	formatter, ok := f[filepath.Ext(file)]
+	if !ok {
+		return byt, nil
+	}
+
+	var out []byte
+	var err error
+	switch formatter {
+	case "gofmt":
+		out, err = format.Source(byt)
+	case "json", "yaml":
+		out, err = canonicalize(formatter, byt)
+	default:
+		out, err = runFilter(formatter, byt)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to format %s with %s", file, formatter)
+	}
+
+	return out, nil
+}
+
+// runFilter runs command (split on whitespace) with byt as its stdin
+// and returns its stdout. If the command fails the error is what it
+// wrote to stderr.
+func runFilter(command string, byt []byte) ([]byte, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, errors.New("command is empty")
+	}
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(byt)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); len(msg) != 0 {
+			return nil, errors.New(msg)
+		}
+		return nil, err
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,573 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig"
+	"github.com/pkg/errors"
+)
+
+// funcMap returns the sprig template functions as well as the
+// additional functions provided by txtplate.
+func funcMap() template.FuncMap {
+	funcs := sprig.TxtFuncMap()
+
+	funcs["buildURL"] = buildURL
+	funcs["pickPaths"] = pickPaths
+	funcs["omitPaths"] = omitPaths
+	funcs["collect"] = collect
+	funcs["sha1sum"] = sha1sum
+	funcs["md5sum"] = md5sum
+	funcs["shortHash"] = shortHash
+	funcs["addDuration"] = addDuration
+	funcs["subDuration"] = subDuration
+	funcs["toRFC3339"] = toRFC3339
+	funcs["humanizeTime"] = humanizeTime
+	funcs["canonicalJson"] = canonicalJSONFunc
+	funcs["canonicalYaml"] = canonicalYAMLFunc
+	funcs["toYamlList"] = toYamlList
+	funcs["cleanList"] = cleanList
+	funcs["expandPath"] = expandPath
+	funcs["jsonPointerEscape"] = jsonPointerEscape
+	funcs["jsonPointerUnescape"] = jsonPointerUnescape
+	funcs["resolvePointer"] = resolvePointer
+	funcs["jsonPatch"] = jsonPatch
+	funcs["kindOf"] = kindOf
+	funcs["isMap"] = isMap
+	funcs["isSlice"] = isSlice
+	funcs["isScalar"] = isScalar
+	funcs["nullIfEmpty"] = nullIfEmpty
+	funcs["toHCL"] = toHCL
+	funcs["formatPhone"] = formatPhone
+	funcs["joinNonEmpty"] = joinNonEmpty
+	funcs["commaSeparated"] = commaSeparated
+	funcs["commentLines"] = commentLines
+	funcs["commentIf"] = commentIf
+	funcs["toIntOr"] = toIntOr
+	funcs["toFloatOr"] = toFloatOr
+	funcs["squeeze"] = squeeze
+	funcs["stripNewlines"] = stripNewlines
+	funcs["shellAssign"] = shellAssign
+	funcs["b64urlenc"] = b64urlenc
+	funcs["b64urldec"] = b64urldec
+	funcs["hexenc"] = hexenc
+	funcs["hexdec"] = hexdec
+	funcs["parseColumns"] = parseColumns
+	funcs["parseColumnsWith"] = parseColumnsWith
+	funcs["regexReplaceTree"] = regexReplaceTree
+	funcs["cron"] = cron
+	funcs["cronNext"] = cronNext
+	funcs["treeView"] = treeView
+	funcs["sectionStart"] = sectionStart
+	funcs["sectionEnd"] = sectionEnd
+	funcs["sectionAppend"] = sectionAppend
+	funcs["renderSections"] = renderSections
+	funcs["mutuallyExclusive"] = mutuallyExclusive
+	funcs["requiredTogether"] = requiredTogether
+
+	return funcs
+}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+	"ftp":   "21",
+}
+
+// buildURL assembles a url from a dict of its components. Recognized keys
+// are scheme, user, password, host, port, path, query and fragment. The port
+// is omitted when it is the default for the scheme. The query may be a map
+// whose values are either scalars or lists of scalars.
+func buildURL(parts map[string]interface{}) (string, error) {
+	u := &url.URL{}
+
+	for key, value := range parts {
+		switch key {
+		case "scheme", "user", "password", "host", "port", "path", "query", "fragment":
+		default:
+			return "", errors.Errorf("buildURL: unknown url component %q", key)
+		}
+
+		if key == "query" || value == nil {
+			continue
+		}
+
+		str := fmt.Sprint(value)
+		switch key {
+		case "scheme":
+			u.Scheme = str
+		case "host":
+			u.Host = str
+		case "path":
+			u.Path = str
+		case "fragment":
+			u.Fragment = str
+		}
+	}
+
+	if user, ok := parts["user"]; ok && user != nil {
+		if password, ok := parts["password"]; ok && password != nil {
+			u.User = url.UserPassword(fmt.Sprint(user), fmt.Sprint(password))
+		} else {
+			u.User = url.User(fmt.Sprint(user))
+		}
+	}
+
+	if port, ok := parts["port"]; ok && port != nil {
+		portStr := fmt.Sprint(port)
+		if len(portStr) != 0 && defaultPorts[strings.ToLower(u.Scheme)] != portStr {
+			u.Host = net.JoinHostPort(u.Host, portStr)
+		}
+	}
+
+	if len(u.Host) != 0 && len(u.Path) != 0 && !strings.HasPrefix(u.Path, "/") {
+		u.Path = "/" + u.Path
+	}
+
+	if query, ok := parts["query"]; ok && query != nil {
+		queryMap, ok := query.(map[string]interface{})
+		if !ok {
+			return "", errors.Errorf("buildURL: query must be a map, got %T", query)
+		}
+
+		values := url.Values{}
+		for k, v := range queryMap {
+			switch v := v.(type) {
+			case []interface{}:
+				for _, elem := range v {
+					values.Add(k, fmt.Sprint(elem))
+				}
+			case []string:
+				for _, elem := range v {
+					values.Add(k, elem)
+				}
+			case nil:
+				values.Add(k, "")
+			default:
+				values.Add(k, fmt.Sprint(v))
+			}
+		}
+
+		u.RawQuery = values.Encode()
+	}
+
+	return u.String(), nil
+}
+
+// pickPaths returns a new map containing only the dotted paths given.
+// The map may be given either as the first or last argument so that it
+// can be used in a pipeline.
+func pickPaths(args ...interface{}) (map[string]interface{}, error) {
+	m, paths, err := mapAndPaths("pickPaths", args)
+	if err != nil {
+		return nil, err
+	}
+
+	picked := map[string]interface{}{}
+	for _, path := range paths {
+		value, ok := lookupPath(m, path)
+		if !ok {
+			continue
+		}
+
+		segments := strings.Split(path, ".")
+		dst := picked
+		for _, segment := range segments[:len(segments)-1] {
+			next, ok := dst[segment].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				dst[segment] = next
+			}
+			dst = next
+		}
+		dst[segments[len(segments)-1]] = copyMaps(value)
+	}
+
+	return picked, nil
+}
+
+// omitPaths returns a copy of the map without the dotted paths given.
+// The map may be given either as the first or last argument so that it
+// can be used in a pipeline.
+func omitPaths(args ...interface{}) (map[string]interface{}, error) {
+	m, paths, err := mapAndPaths("omitPaths", args)
+	if err != nil {
+		return nil, err
+	}
+
+	omitted := copyMaps(m).(map[string]interface{})
+	for _, path := range paths {
+		segments := strings.Split(path, ".")
+		dst := omitted
+		for _, segment := range segments[:len(segments)-1] {
+			next, ok := dst[segment].(map[string]interface{})
+			if !ok {
+				dst = nil
+				break
+			}
+			dst = next
+		}
+		if dst != nil {
+			delete(dst, segments[len(segments)-1])
+		}
+	}
+
+	return omitted, nil
+}
+
+// sha1sum returns the hex encoded sha1 digest of input, it is the legacy
+// companion to sprig's sha256sum.
+func sha1sum(input string) string {
+	hash := sha1.Sum([]byte(input))
+	return hex.EncodeToString(hash[:])
+}
+
+// md5sum returns the hex encoded md5 digest of input, it is the legacy
+// companion to sprig's sha256sum.
+func md5sum(input string) string {
+	hash := md5.Sum([]byte(input))
+	return hex.EncodeToString(hash[:])
+}
+
+// cleanList removes nils, empty strings and duplicates from a list and
+// sorts what remains. Elements are compared and sorted by their string
+// representation so lists of mixed types are handled.
+func cleanList(list interface{}) ([]interface{}, error) {
+	if list == nil {
+		return []interface{}{}, nil
+	}
+
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, errors.Errorf("cleanList: expected a list, got %T", list)
+	}
+
+	type elem struct {
+		str   string
+		value interface{}
+	}
+
+	seen := make(map[string]struct{}, v.Len())
+	elems := make([]elem, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		value := v.Index(i).Interface()
+		if value == nil {
+			continue
+		}
+
+		str := fmt.Sprint(value)
+		if len(str) == 0 {
+			continue
+		}
+		if _, ok := seen[str]; ok {
+			continue
+		}
+
+		seen[str] = struct{}{}
+		elems = append(elems, elem{str: str, value: value})
+	}
+
+	sort.Slice(elems, func(i, j int) bool { return elems[i].str < elems[j].str })
+
+	cleaned := make([]interface{}, len(elems))
+	for i, e := range elems {
+		cleaned[i] = e.value
+	}
+
+	return cleaned, nil
+}
+
+// expandPath expands a leading ~ or ~user to a home directory and any
+// $VAR or ${VAR} from the environment, then makes the path absolute.
+func expandPath(path string) (string, error) {
+	if strings.HasPrefix(path, "~") {
+		rest := path[1:]
+		name := rest
+		if idx := strings.IndexByte(rest, '/'); idx >= 0 {
+			name, rest = rest[:idx], rest[idx:]
+		} else {
+			rest = ""
+		}
+
+		var home string
+		if len(name) == 0 {
+			var err error
+			if home, err = os.UserHomeDir(); err != nil {
+				return "", errors.Wrap(err, "expandPath: failed to find home directory")
+			}
+		} else {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", errors.Wrapf(err, "expandPath: failed to find home directory of %s", name)
+			}
+			home = u.HomeDir
+		}
+
+		path = home + rest
+	}
+
+	abs, err := filepath.Abs(os.ExpandEnv(path))
+	if err != nil {
+		return "", errors.Wrap(err, "expandPath")
+	}
+
+	return abs, nil
+}
+
+// joinNonEmpty joins items with sep, leaving out nils and empty strings so
+// there are never stray separators. Lists given as items are flattened.
+func joinNonEmpty(sep string, items ...interface{}) string {
+	strs := make([]string, 0, len(items))
+	for _, item := range items {
+		v := reflect.ValueOf(item)
+		if item != nil && (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) {
+			for i := 0; i < v.Len(); i++ {
+				strs = appendNonEmpty(strs, v.Index(i).Interface())
+			}
+			continue
+		}
+		strs = appendNonEmpty(strs, item)
+	}
+
+	return strings.Join(strs, sep)
+}
+
+func appendNonEmpty(strs []string, item interface{}) []string {
+	if item == nil {
+		return strs
+	}
+	if str := fmt.Sprint(item); len(str) != 0 {
+		strs = append(strs, str)
+	}
+	return strs
+}
+
+// commaSeparated joins the non-empty elements of list with ", ".
+func commaSeparated(list interface{}) string {
+	return joinNonEmpty(", ", list)
+}
+
+// commentLines puts prefix at the start of every line in str. A trailing
+// newline does not count as starting another line.
+func commentLines(prefix, str string) string {
+	trailing := strings.HasSuffix(str, "\n")
+	lines := strings.Split(strings.TrimSuffix(str, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = prefix + line
+	}
+
+	commented := strings.Join(lines, "\n")
+	if trailing {
+		commented += "\n"
+	}
+	return commented
+}
+
+// commentIf is commentLines when cond is true, otherwise str is returned
+// as is. cond is true or false the same way it is for if.
+func commentIf(cond interface{}, prefix, str string) string {
+	if truth, _ := template.IsTrue(cond); !truth {
+		return str
+	}
+	return commentLines(prefix, str)
+}
+
+// squeeze collapses every run of whitespace in str to a single space and
+// trims it from both ends.
+func squeeze(str string) string {
+	return strings.Join(strings.Fields(str), " ")
+}
+
+// stripNewlines joins the lines of str with a single space. Whitespace
+// around each line break and blank lines are dropped, whitespace within a
+// line is left alone.
+func stripNewlines(str string) string {
+	lines := strings.Split(str, "\n")
+	joined := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); len(line) != 0 {
+			joined = append(joined, line)
+		}
+	}
+	return strings.Join(joined, " ")
+}
+
+// shortHash returns the first length hex characters of the sha256 of value.
+// Strings are hashed as they are, anything else is hashed as json which
+// sorts map keys so the hash doesn't depend on map ordering.
+func shortHash(value interface{}, length int) (string, error) {
+	if length < 1 || length > sha256.Size*2 {
+		return "", errors.Errorf("shortHash: length must be between 1 and %d, got %d", sha256.Size*2, length)
+	}
+
+	var byt []byte
+	if str, ok := value.(string); ok {
+		byt = []byte(str)
+	} else {
+		var err error
+		if byt, err = json.Marshal(value); err != nil {
+			return "", errors.Wrap(err, "shortHash: failed to canonicalize value")
+		}
+	}
+
+	hash := sha256.Sum256(byt)
+	return hex.EncodeToString(hash[:])[:length], nil
+}
+
+// b64urlenc encodes str with the url safe base64 alphabet and no padding,
+// as used by JWTs.
+func b64urlenc(str string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(str))
+}
+
+// b64urldec decodes url safe base64 with or without padding.
+func b64urldec(str string) (string, error) {
+	byt, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(str, "="))
+	if err != nil {
+		return "", errors.Wrap(err, "b64urldec")
+	}
+	return string(byt), nil
+}
+
+func hexenc(str string) string {
+	return hex.EncodeToString([]byte(str))
+}
+
+func hexdec(str string) (string, error) {
+	byt, err := hex.DecodeString(str)
+	if err != nil {
+		return "", errors.Wrap(err, "hexdec")
+	}
+	return string(byt), nil
+}
+
+// mapAndPaths splits template function arguments into a map that may be
+// either the first or the last argument and a list of string paths.
+func mapAndPaths(fn string, args []interface{}) (map[string]interface{}, []string, error) {
+	if len(args) == 0 {
+		return nil, nil, errors.Errorf("%s: missing map argument", fn)
+	}
+
+	var m map[string]interface{}
+	if first, ok := args[0].(map[string]interface{}); ok {
+		m, args = first, args[1:]
+	} else if last, ok := args[len(args)-1].(map[string]interface{}); ok {
+		m, args = last, args[:len(args)-1]
+	} else {
+		return nil, nil, errors.Errorf("%s: expected a map as the first or last argument", fn)
+	}
+
+	paths := make([]string, len(args))
+	for i, arg := range args {
+		path, ok := arg.(string)
+		if !ok {
+			return nil, nil, errors.Errorf("%s: expected path to be a string, got %T", fn, arg)
+		}
+		paths[i] = path
+	}
+
+	return m, paths, nil
+}
+
+// lookupPath finds the value at the dotted path inside of m.
+func lookupPath(m map[string]interface{}, path string) (interface{}, bool) {
+	var value interface{} = m
+	for _, segment := range strings.Split(path, ".") {
+		current, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = current[segment]; !ok {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// collect returns every value in data found at the dotted pattern. A *
+// segment matches every key of a map (in sorted order) or every element
+// of a list, a number segment indexes a list. Paths that don't exist
+// are left out.
+func collect(pattern string, data interface{}) []interface{} {
+	matches := []interface{}{}
+	return collectHelper(matches, strings.Split(pattern, "."), data)
+}
+
+func collectHelper(matches []interface{}, segments []string, value interface{}) []interface{} {
+	if len(segments) == 0 {
+		return append(matches, value)
+	}
+	segment, rest := segments[0], segments[1:]
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if segment != "*" {
+			if elem, ok := v[segment]; ok {
+				matches = collectHelper(matches, rest, elem)
+			}
+			return matches
+		}
+
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			matches = collectHelper(matches, rest, v[k])
+		}
+	case []interface{}:
+		if segment != "*" {
+			if idx, err := strconv.Atoi(segment); err == nil && idx >= 0 && idx < len(v) {
+				matches = collectHelper(matches, rest, v[idx])
+			}
+			return matches
+		}
+
+		for _, elem := range v {
+			matches = collectHelper(matches, rest, elem)
+		}
+	}
+
+	return matches
+}
+
+// copyMaps recursively copies any map[string]interface{} and []interface{}
+// found in value, all other values are shared with the original.
+func copyMaps(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		newMap := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			newMap[k] = copyMaps(elem)
+		}
+		return newMap
+	case []interface{}:
+		newSlice := make([]interface{}, len(v))
+		for i, elem := range v {
+			newSlice[i] = copyMaps(elem)
+		}
+		return newSlice
+	default:
+		return value
+	}
+}
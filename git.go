@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// gitFuncs returns template functions that report on the state of the git
+// repository in the working directory. When allowed is false the functions
+// are still defined but fail, so templates get told about --allow-git rather
+// than about an undefined function.
+func gitFuncs(allowed bool) template.FuncMap {
+	if !allowed {
+		disallowed := func(name string) func() (string, error) {
+			return func() (string, error) {
+				return "", errors.Errorf("%s: git functions require --allow-git", name)
+			}
+		}
+		return template.FuncMap{
+			"gitSHA":    disallowed("gitSHA"),
+			"gitBranch": disallowed("gitBranch"),
+			"gitDirty":  disallowed("gitDirty"),
+		}
+	}
+
+	return template.FuncMap{
+		"gitSHA":    gitSHA,
+		"gitBranch": gitBranch,
+		"gitDirty":  gitDirty,
+	}
+}
+
+// gitSHA returns the commit hash of HEAD.
+func gitSHA() (string, error) {
+	return runGit("gitSHA", "rev-parse", "HEAD")
+}
+
+// gitBranch returns the name of the checked out branch, or an empty string
+// if HEAD is detached.
+func gitBranch() (string, error) {
+	branch, err := runGit("gitBranch", "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	if branch == "HEAD" {
+		return "", nil
+	}
+
+	return branch, nil
+}
+
+// gitDirty reports whether the working tree has uncommitted changes
+// including untracked files.
+func gitDirty() (bool, error) {
+	status, err := runGit("gitDirty", "status", "--porcelain")
+	if err != nil {
+		return false, err
+	}
+
+	return len(status) != 0, nil
+}
+
+// runGit runs git with args and returns its trimmed stdout. On failure
+// the error contains what git wrote to stderr.
+func runGit(fn string, args ...string) (string, error) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+	cmd := exec.Command("git", args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); len(msg) != 0 {
+			return "", errors.Errorf("%s: %s", fn, msg)
+		}
+		return "", errors.Wrapf(err, "%s: failed to run git", fn)
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/hcl/printer"
+	"github.com/pkg/errors"
+)
+
+var hclIdentifier = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
+
+// toHCL renders a map as HCL. Nested maps become blocks, lists made up
+// entirely of maps become repeated blocks and everything else becomes an
+// attribute. Attributes are written before blocks, both sorted by key, and
+// nil values are left out since HCL has no null. The result is passed
+// through the HCL printer so it is formatted the same way hclfmt would.
+func toHCL(value interface{}) (string, error) {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return "", errors.Errorf("toHCL: expected a map, got %T", value)
+	}
+
+	buf := &strings.Builder{}
+	if err := writeHCLBody(buf, m); err != nil {
+		return "", errors.Wrap(err, "toHCL")
+	}
+
+	formatted, err := printer.Format([]byte(buf.String()))
+	if err != nil {
+		return "", errors.Wrap(err, "toHCL: failed to format")
+	}
+
+	return strings.TrimSuffix(string(formatted), "\n"), nil
+}
+
+func writeHCLBody(buf *strings.Builder, m map[string]interface{}) error {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	// Attributes come before blocks, each sorted by key
+	sort.Slice(keys, func(i, j int) bool {
+		iBlock, jBlock := isHCLBlock(m[keys[i]]), isHCLBlock(m[keys[j]])
+		if iBlock != jBlock {
+			return jBlock
+		}
+		return keys[i] < keys[j]
+	})
+
+	for _, k := range keys {
+		key := hclKey(k)
+
+		switch v := m[k].(type) {
+		case nil:
+		case map[string]interface{}:
+			if err := writeHCLBlock(buf, key, v); err != nil {
+				return err
+			}
+		case []interface{}:
+			if isHCLBlock(v) {
+				for _, elem := range v {
+					if err := writeHCLBlock(buf, key, elem.(map[string]interface{})); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			value, err := hclValue(v)
+			if err != nil {
+				return errors.Wrapf(err, "key %s", k)
+			}
+			fmt.Fprintf(buf, "%s = %s\n", key, value)
+		default:
+			value, err := hclValue(v)
+			if err != nil {
+				return errors.Wrapf(err, "key %s", k)
+			}
+			fmt.Fprintf(buf, "%s = %s\n", key, value)
+		}
+	}
+
+	return nil
+}
+
+func writeHCLBlock(buf *strings.Builder, key string, m map[string]interface{}) error {
+	fmt.Fprintf(buf, "%s {\n", key)
+	if err := writeHCLBody(buf, m); err != nil {
+		return err
+	}
+	buf.WriteString("}\n")
+
+	return nil
+}
+
+// hclValue renders the right hand side of an attribute.
+func hclValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v), nil
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []interface{}:
+		elems := make([]string, 0, len(v))
+		for _, elem := range v {
+			str, err := hclValue(elem)
+			if err != nil {
+				return "", err
+			}
+			elems = append(elems, str)
+		}
+		return "[" + strings.Join(elems, ", ") + "]", nil
+	case map[string]interface{}:
+		buf := &strings.Builder{}
+		buf.WriteString("{\n")
+		if err := writeHCLBody(buf, v); err != nil {
+			return "", err
+		}
+		buf.WriteString("}")
+		return buf.String(), nil
+	default:
+		return "", errors.Errorf("cannot render %T as hcl", value)
+	}
+}
+
+// hclKey quotes keys that aren't valid identifiers.
+func hclKey(key string) string {
+	if hclIdentifier.MatchString(key) {
+		return key
+	}
+	return strconv.Quote(key)
+}
+
+// isHCLBlock reports whether value is rendered as one or more blocks.
+func isHCLBlock(value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return true
+	case []interface{}:
+		return len(v) != 0 && allMaps(v)
+	}
+	return false
+}
+
+func allMaps(list []interface{}) bool {
+	for _, elem := range list {
+		if _, ok := elem.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// jsonPatch computes the RFC6902 json patch that transforms old into new
+// and returns it marshaled as a json array. Maps are diffed key by key and
+// lists index by index, anything else that differs is replaced.
+func jsonPatch(old, new interface{}) (string, error) {
+	oldValue, err := normalizeJSON(old)
+	if err != nil {
+		return "", errors.Wrap(err, "jsonPatch: failed to convert old value")
+	}
+	newValue, err := normalizeJSON(new)
+	if err != nil {
+		return "", errors.Wrap(err, "jsonPatch: failed to convert new value")
+	}
+
+	ops := diffJSON(nil, "", oldValue, newValue)
+	if ops == nil {
+		ops = []map[string]interface{}{}
+	}
+
+	byt, err := json.Marshal(ops)
+	if err != nil {
+		return "", errors.Wrap(err, "jsonPatch: failed to marshal patch")
+	}
+
+	return string(byt), nil
+}
+
+// normalizeJSON round trips value through encoding/json so that values
+// that came from different formats (eg. int vs float64) compare equal.
+func normalizeJSON(value interface{}) (interface{}, error) {
+	byt, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var normalized interface{}
+	err = json.Unmarshal(byt, &normalized)
+	return normalized, err
+}
+
+func diffJSON(ops []map[string]interface{}, path string, old, new interface{}) []map[string]interface{} {
+	switch oldValue := old.(type) {
+	case map[string]interface{}:
+		newValue, ok := new.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		keys := make([]string, 0, len(oldValue)+len(newValue))
+		for k := range oldValue {
+			keys = append(keys, k)
+		}
+		for k := range newValue {
+			if _, ok := oldValue[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			keyPath := path + "/" + jsonPointerEscape(k)
+			oldElem, inOld := oldValue[k]
+			newElem, inNew := newValue[k]
+
+			switch {
+			case !inNew:
+				ops = append(ops, patchOp("remove", keyPath))
+			case !inOld:
+				ops = append(ops, patchOp("add", keyPath, newElem))
+			default:
+				ops = diffJSON(ops, keyPath, oldElem, newElem)
+			}
+		}
+		return ops
+	case []interface{}:
+		newValue, ok := new.([]interface{})
+		if !ok {
+			break
+		}
+
+		common := len(oldValue)
+		if len(newValue) < common {
+			common = len(newValue)
+		}
+
+		for i := 0; i < common; i++ {
+			ops = diffJSON(ops, path+"/"+strconv.Itoa(i), oldValue[i], newValue[i])
+		}
+		for i := common; i < len(newValue); i++ {
+			ops = append(ops, patchOp("add", path+"/"+strconv.Itoa(i), newValue[i]))
+		}
+		// Remove from the end so earlier indexes stay valid
+		for i := len(oldValue) - 1; i >= common; i-- {
+			ops = append(ops, patchOp("remove", path+"/"+strconv.Itoa(i)))
+		}
+		return ops
+	}
+
+	if !reflect.DeepEqual(old, new) {
+		ops = append(ops, patchOp("replace", path, new))
+	}
+
+	return ops
+}
+
+func patchOp(op, path string, value ...interface{}) map[string]interface{} {
+	m := map[string]interface{}{
+		"op":   op,
+		"path": path,
+	}
+	if len(value) != 0 {
+		m["value"] = value[0]
+	}
+
+	return m
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	pointerEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+// jsonPointerEscape escapes a single reference token of a json pointer
+// as described in RFC6901.
+func jsonPointerEscape(token string) string {
+	return pointerEscaper.Replace(token)
+}
+
+// jsonPointerUnescape reverses jsonPointerEscape.
+func jsonPointerUnescape(token string) string {
+	return pointerUnescaper.Replace(token)
+}
+
+// resolvePointer navigates data using the RFC6901 json pointer given.
+// An empty pointer refers to data itself.
+func resolvePointer(data interface{}, pointer string) (interface{}, error) {
+	if len(pointer) == 0 {
+		return data, nil
+	}
+	if pointer[0] != '/' {
+		return nil, errors.Errorf("resolvePointer: pointer %q must start with /", pointer)
+	}
+
+	value := data
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = jsonPointerUnescape(token)
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			elem, ok := v[token]
+			if !ok {
+				return nil, errors.Errorf("resolvePointer: key %q in %q does not exist", token, pointer)
+			}
+			value = elem
+		case []interface{}:
+			idx, err := pointerIndex(token)
+			if err != nil {
+				return nil, errors.Wrapf(err, "resolvePointer: bad array index in %q", pointer)
+			}
+			if idx >= len(v) {
+				return nil, errors.Errorf("resolvePointer: index %d in %q is out of range", idx, pointer)
+			}
+			value = v[idx]
+		default:
+			return nil, errors.Errorf("resolvePointer: cannot index %T with %q in %q", value, token, pointer)
+		}
+	}
+
+	return value, nil
+}
+
+// pointerIndex parses an array index token, leading zeros are not allowed.
+func pointerIndex(token string) (int, error) {
+	if len(token) == 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, errors.Errorf("invalid index %q", token)
+	}
+	for _, c := range token {
+		if c < '0' || c > '9' {
+			return 0, errors.Errorf("invalid index %q", token)
+		}
+	}
+
+	return strconv.Atoi(token)
+}
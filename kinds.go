@@ -0,0 +1,103 @@
+package main
+
+import (
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// kindOf returns a coarse kind for value: map, slice, string, int, float,
+// bool or nil. It replaces sprig's kindOf, which reports reflect kinds like
+// float64 and invalid that are awkward to compare against in templates.
+// sprig's kindIs still compares reflect kinds. Kinds not listed (eg.
+// structs) are reported by their reflect kind. Note that all numbers in
+// json values files are decoded as floats.
+func kindOf(value interface{}) string {
+	if value == nil {
+		return "nil"
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Map:
+		return "map"
+	case reflect.Slice, reflect.Array:
+		return "slice"
+	case reflect.String:
+		return "string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "int"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return "nil"
+		}
+		return kindOf(v.Elem().Interface())
+	default:
+		return v.Kind().String()
+	}
+}
+
+// isMap reports whether value is a map.
+func isMap(value interface{}) bool {
+	return kindOf(value) == "map"
+}
+
+// isSlice reports whether value is a slice or array.
+func isSlice(value interface{}) bool {
+	return kindOf(value) == "slice"
+}
+
+// isScalar reports whether value is a string, number or bool.
+func isScalar(value interface{}) bool {
+	switch kindOf(value) {
+	case "string", "int", "float", "bool":
+		return true
+	}
+	return false
+}
+
+// nullIfEmpty returns the empty token for format when value is nil, an empty
+// string or an empty map or list, otherwise value is returned unchanged.
+// Unlike sprig's empty, zero and false are not considered empty. The tokens
+// are null for json, ~ for yaml and for toml, which has no null, an empty
+// inline table, array or string depending on the value.
+func nullIfEmpty(value interface{}, format string) (interface{}, error) {
+	if !isEmptyValue(value) {
+		return value, nil
+	}
+
+	switch format {
+	case "json":
+		return "null", nil
+	case "yaml", "yml":
+		return "~", nil
+	case "toml":
+		switch kindOf(value) {
+		case "map":
+			return "{}", nil
+		case "slice":
+			return "[]", nil
+		default:
+			return `""`, nil
+		}
+	default:
+		return nil, errors.Errorf("nullIfEmpty: unknown format %q", format)
+	}
+}
+
+// isEmptyValue reports whether value is nil, an empty string or an empty map
+// or list. Zero and false are not empty.
+func isEmptyValue(value interface{}) bool {
+	switch kindOf(value) {
+	case "nil":
+		return true
+	case "string", "map", "slice":
+		return reflect.ValueOf(value).Len() == 0
+	}
+	return false
+}
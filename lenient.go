@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/pkg/errors"
+)
+
+// yamlTabWidth is the number of spaces each tab in a line's indentation
+// is replaced with when parsing yaml leniently.
+const yamlTabWidth = 2
+
+// parseLenientYAML parses yaml that would otherwise be rejected or
+// silently mangled. Tabs in indentation are replaced with spaces and
+// duplicate keys are allowed with the last one winning. Non-string keys
+// (eg. yes, no, 1) are converted to strings. A warning is printed to
+// stderr for each thing that was tolerated.
+func parseLenientYAML(file string, byt []byte) (map[string]interface{}, error) {
+	byt, lines := expandYAMLTabs(byt)
+	if lines != 0 {
+		fmt.Fprintf(os.Stderr, "warning: %s: replaced tab indentation on %d line(s)\n", file, lines)
+	}
+
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(byt, &doc); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse values file %s as yaml", file)
+	}
+
+	return fromMapSlice(file, "", doc), nil
+}
+
+// expandYAMLTabs replaces tabs in the indentation of each line with spaces
+// and returns how many lines were changed.
+func expandYAMLTabs(byt []byte) ([]byte, int) {
+	lines := bytes.Split(byt, []byte("\n"))
+	changed := 0
+	spaces := bytes.Repeat([]byte(" "), yamlTabWidth)
+
+	for i, line := range lines {
+		indent := len(line) - len(bytes.TrimLeft(line, " \t"))
+		if bytes.IndexByte(line[:indent], '\t') < 0 {
+			continue
+		}
+
+		expanded := bytes.Replace(line[:indent], []byte("\t"), spaces, -1)
+		lines[i] = append(expanded, line[indent:]...)
+		changed++
+	}
+
+	return bytes.Join(lines, []byte("\n")), changed
+}
+
+// fromMapSlice converts a yaml.MapSlice and any nested in it to
+// map[string]interface{} warning about keys that appear twice.
+func fromMapSlice(file, path string, slice yaml.MapSlice) map[string]interface{} {
+	m := make(map[string]interface{}, len(slice))
+	for _, item := range slice {
+		key := fmt.Sprint(item.Key)
+		keyPath := key
+		if len(path) != 0 {
+			keyPath = path + "." + key
+		}
+
+		if _, ok := item.Key.(string); !ok {
+			fmt.Fprintf(os.Stderr, "warning: %s: key %s is a %T, using it as a string\n", file, keyPath, item.Key)
+		}
+
+		if _, ok := m[key]; ok {
+			fmt.Fprintf(os.Stderr, "warning: %s: duplicate key %s, using the last value\n", file, keyPath)
+		}
+		m[key] = fromMapSliceValue(file, keyPath, item.Value)
+	}
+
+	return m
+}
+
+func fromMapSliceValue(file, path string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		return fromMapSlice(file, path, v)
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = fromMapSliceValue(file, fmt.Sprintf("%s.%d", path, i), elem)
+		}
+		return v
+	default:
+		return value
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// limitWriter fails any write that would take the total written past
+// limit, there are no partial writes.
+type limitWriter struct {
+	w       io.Writer
+	limit   int64
+	written int64
+}
+
+func (l *limitWriter) Write(p []byte) (int, error) {
+	if l.written+int64(len(p)) > l.limit {
+		return 0, errors.Errorf("output exceeded the maximum size of %d bytes", l.limit)
+	}
+
+	n, err := l.w.Write(p)
+	l.written += int64(n)
+	return n, err
+}
+
+var sizeSuffixes = []struct {
+	suffix string
+	size   int64
+}{
+	{"GB", 1 << 30}, {"G", 1 << 30},
+	{"MB", 1 << 20}, {"M", 1 << 20},
+	{"KB", 1 << 10}, {"K", 1 << 10},
+	{"B", 1},
+}
+
+// parseSize parses a byte count with an optional K, M or G suffix
+// (powers of 1024).
+func parseSize(size string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(size))
+	multiplier := int64(1)
+	for _, s := range sizeSuffixes {
+		if strings.HasSuffix(str, s.suffix) {
+			str, multiplier = strings.TrimSpace(strings.TrimSuffix(str, s.suffix)), s.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.Errorf("invalid size %q", size)
+	}
+
+	return n * multiplier, nil
+}
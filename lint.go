@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// lintDelims looks for actions opened with left that are never closed with
+// right and reports the line and column of the first one. Quoted strings and
+// comments inside of actions are skipped so delimiters inside of them are
+// not counted. A right delimiter outside of an action is plain text and is
+// not an error.
+func lintDelims(text, left, right string) error {
+	pos := 0
+	for {
+		start := strings.Index(text[pos:], left)
+		if start < 0 {
+			return nil
+		}
+		start += pos
+
+		end, err := actionEnd(text, start+len(left), left, right)
+		if err != nil {
+			line, col := lineCol(text, start)
+			return errors.Errorf("line %d, col %d: unclosed action, %s", line, col, err)
+		}
+		pos = end + len(right)
+	}
+}
+
+// actionEnd returns the position of the right delimiter that closes the action
+// whose body begins at pos.
+func actionEnd(text string, pos int, left, right string) (int, error) {
+	for pos < len(text) {
+		switch {
+		case strings.HasPrefix(text[pos:], right):
+			return pos, nil
+		case strings.HasPrefix(text[pos:], left):
+			line, col := lineCol(text, pos)
+			return 0, errors.Errorf("found %q at line %d, col %d before %q", left, line, col, right)
+		case strings.HasPrefix(text[pos:], "/*"):
+			end := strings.Index(text[pos+2:], "*/")
+			if end < 0 {
+				return 0, errors.New("comment is never closed")
+			}
+			pos += 2 + end + 2
+		case text[pos] == '"' || text[pos] == '\'' || text[pos] == '`':
+			end, ok := quoteEnd(text, pos)
+			if !ok {
+				return 0, errors.Errorf("quote %c is never closed", text[pos])
+			}
+			pos = end + 1
+		default:
+			pos++
+		}
+	}
+
+	return 0, errors.Errorf("missing %q", right)
+}
+
+// quoteEnd returns the position of the quote that closes the one at pos.
+// Escapes are honored except in raw (backtick) strings.
+func quoteEnd(text string, pos int) (int, bool) {
+	quote := text[pos]
+	for i := pos + 1; i < len(text); i++ {
+		switch {
+		case text[i] == '\\' && quote != '`':
+			i++
+		case text[i] == quote:
+			return i, true
+		case text[i] == '\n' && quote != '`':
+			return 0, false
+		}
+	}
+
+	return 0, false
+}
+
+// lineCol converts a byte offset in text to a 1-based line and column.
+func lineCol(text string, pos int) (int, int) {
+	line := 1 + strings.Count(text[:pos], "\n")
+	col := pos - strings.LastIndex(text[:pos], "\n")
+	return line, col
+}
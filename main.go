@@ -9,18 +9,54 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
 	"text/template"
+	"time"
 
 	yaml "gopkg.in/yaml.v2"
 
-	"github.com/Masterminds/sprig"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
 var (
-	flagInput  string
-	flagOutput string
+	flagInput            string
+	flagBundle           string
+	flagTemplate         string
+	flagOutput           string
+	flagDumpValues       string
+	flagDumpValuesFormat string
+	flagStrictDelims     bool
+	flagAllowGit         bool
+	flagResolve          []string
+	flagSops             bool
+	flagCanonicalize     string
+	flagMissingValues    string
+	flagDefine           []string
+	flagDotenvUpper      bool
+	flagAllowAWS         bool
+	flagLenientYAML      bool
+	flagFormatByExt      []string
+	flagNoYAMLAnchors    bool
+	flagMaxOutputSize    string
+	flagPlaceholder      string
+	flagInputEncoding    string
+	flagOutputEncoding   string
+	flagAllowFake        bool
+	flagSeed             int64
+	flagCPUProfile       string
+	flagMemProfile       string
+	flagMergeSemantics   string
+	flagTemplatePaths    bool
+	flagLoaders          []string
+	flagDecryptCmd       string
+	flagStateOutput      string
+	flagMergeReport      string
+	flagPerFile          bool
+	flagOutputDir        string
+	flagManifest         string
+	flagTransformValues  []string
 )
 
 var rootCmd = cobra.Command{
@@ -31,8 +67,69 @@ and output the result to stdout (or --output). Template functions available
 are from the sprig (https://github.com/Masterminds/sprig) package. Detects
 the file type of valuesfile based on extension, defaults to json if omitted.
 
+A values argument of the form ssm://path/prefix (with --allow-aws) fetches
+every SSM parameter under /path/prefix using the aws cli and its usual
+credential discovery. Each parameter is nested by the segments of its name
+after the prefix, so /path/prefix/db/host becomes .db.host.
+
+Other schemes can be handled by a go plugin (go build -buildmode=plugin)
+registered with --loader scheme=path/to/plugin.so. The plugin must export
+the function Load(uri string) (map[string]interface{}, error) which is
+called with the whole argument, eg. myscheme://path. Plugins take priority
+over the built-in schemes.
+
+Values files are merged in order, keys in later files replace keys in earlier
+ones and maps are merged recursively. --resolve path:strategy changes this for
+the dotted path given. The strategies first and last keep the earlier or later
+value whatever its type (a map is then kept or replaced whole). The strategies
+max, min and sum only apply when both values are numbers, otherwise the later
+value wins as usual. A null in a later file sets the key to null.
+
+A key ending in << appends to the key without it instead of replacing it,
+so "path<<": ":/opt/bin" adds to path. Strings are concatenated, lists are
+joined, anything else is added to the end of a list and a missing or null
+key is simply set. Appending to a map or appending to a string something
+that isn't a string is an error. In the same file key<< is applied after
+key.
+
+--merge-semantics rfc7386 merges each file as a JSON Merge Patch (RFC7386)
+instead. The differences are that a null deletes the key rather than
+setting it to null, and that --resolve and << are ignored. Maps are still
+merged recursively and everything else, including lists, is replaced.
+
+--merge-report writes a json object keyed by the dotted path of every leaf
+value (lists count as leaves) giving the file its final value came from,
+every file that set it and whether it was overridden.
+
+--bundle renders an archive (.tar.gz, .tgz, .tar or .zip) holding a
+template.tpl and its values files. The shallowest template.tpl is used and
+every .json, .yaml and .yml file beside or below it is merged in lexical
+order, before any values files given as arguments.
+
+--per-file renders the template once for each values file on its own
+instead of merging them. Each output is written to --output-dir (created if
+missing) named after its values file, with the extension of --input less
+any .tpl or .tmpl suffix, so values/acme.yaml with -i config.json.tpl
+becomes acme.json. With --template, --bundle or stdin there is no template
+file name to take an extension from, so the outputs have none (acme).
+
+Missing keys and nil values render as <no value>. --missing-placeholder
+replaces that text in the output with something more obvious like TODO.
+The replacement is done on the rendered output, so a literal <no value> in
+the template is replaced too. Missing keys are never an error.
+
+Note that kindOf differs from sprig's: it returns map, slice, string, int,
+float, bool or nil rather than reflect kinds, so a json number is float
+instead of float64 and a missing value is nil instead of invalid. kindIs
+still takes reflect kinds (kindIs "float64" .port).
+
+stateAppend "key" value collects values under a key while rendering and
+stateGet "key" returns them. --state-output writes everything collected to
+a file as a json object of lists once rendering is done.
+
 Example:
 	cat mytemplate.tpl | txtplate values.json > output.txt
+	txtplate --template 'Host: {{ .host }}' values.json
 `,
 	RunE: doTemplating,
 }
@@ -40,8 +137,50 @@ Example:
 func main() {
 	flags := rootCmd.Flags()
 	flags.StringVarP(&flagInput, "input", "i", "", "Input from the file given instead of stdin")
+	flags.StringVar(&flagBundle, "bundle", "", "Render the template.tpl in this .tar.gz, .tar or .zip with the values files beside it")
+	flags.StringVarP(&flagTemplate, "template", "t", "", "Use the template text given instead of stdin or --input")
+	flags.StringVar(&flagInputEncoding, "input-encoding", "", "Character encoding of the template, it is converted to utf-8 before parsing (eg. latin1, utf-16le)")
+	flags.StringVar(&flagOutputEncoding, "output-encoding", "", "Character encoding to convert the output to from utf-8")
+	flags.StringArrayVar(&flagDefine, "define", nil, "Define a named template from the command line, replacing any of the same name (name=body, repeatable)")
 	flags.StringVarP(&flagOutput, "output", "o", "", "Output from the file given instead of stdout")
-	rootCmd.Args = cobra.MinimumNArgs(1)
+	flags.BoolVar(&flagPerFile, "per-file", false, "Render the template once for each values file instead of merging them, writing to --output-dir")
+	flags.StringVar(&flagOutputDir, "output-dir", "", "Directory --per-file writes its outputs to (created if missing), named after each values file")
+	flags.StringVar(&flagPlaceholder, "missing-placeholder", "", "Replace the <no value> printed for missing or nil values with this text")
+	flags.StringVar(&flagMaxOutputSize, "max-output-size", "", "Abort without writing anything if the output grows past this size (eg. 512K, 10M)")
+	flags.StringVar(&flagCanonicalize, "canonicalize-output", "", "Re-emit the output with sorted keys and consistent formatting (json or yaml)")
+	flags.StringArrayVar(&flagFormatByExt, "format-by-ext", nil, "Format --output by its extension, built-ins are gofmt, json and yaml, others run as commands (eg. .go:gofmt,.json:jq, repeatable)")
+	flags.StringVar(&flagStateOutput, "state-output", "", "Write what templates added with stateAppend to this file as json")
+	flags.StringVar(&flagManifest, "manifest", "", "Write a json list of every file written, with sizes and sha256 hashes, to this file")
+	flags.StringArrayVar(&flagTransformValues, "transform-values", nil, "Replace regex matches in every string value after merging (pattern=replacement, repeatable)")
+	flags.StringVar(&flagDumpValues, "dump-values", "", "Also write the merged values to the file given")
+	flags.StringVar(&flagMergeReport, "merge-report", "", "Write which values file each key's final value came from to this file as json")
+	flags.StringVar(&flagDumpValuesFormat, "dump-values-format", "json", "Format of the --dump-values file (json or yaml)")
+	flags.BoolVar(&flagStrictDelims, "strict-delims", false, "Check for unclosed actions before compiling the template")
+	flags.BoolVar(&flagDotenvUpper, "dotenv-upper", true, "Convert toDotenv keys to UPPER_SNAKE case")
+	flags.BoolVar(&flagAllowGit, "allow-git", false, "Allow the gitSHA, gitBranch and gitDirty template functions to run git")
+	flags.BoolVar(&flagAllowFake, "allow-fake", false, "Allow the fake* template functions that generate random test data")
+	flags.Int64Var(&flagSeed, "seed", 0, "Seed for the fake* template functions, random if not given")
+	flags.BoolVar(&flagTemplatePaths, "template-values-paths", false, "Render values file arguments as templates against the environment first (eg. 'values/{{ env \"ENV\" }}.yaml')")
+	flags.StringVar(&flagMissingValues, "missing-values", "error", "What to do with values files that don't exist: error, empty (treat as {}) or skip")
+	flags.StringArrayVar(&flagLoaders, "loader", nil, "Load scheme://... values arguments with a go plugin (scheme=path.so, repeatable)")
+	flags.BoolVar(&flagAllowAWS, "allow-aws", false, "Allow ssm://path values arguments to fetch parameters with the aws cli")
+	flags.BoolVar(&flagLenientYAML, "lenient-yaml", false, "Tolerate tab indentation and duplicate keys in yaml values files, warning on stderr")
+	flags.BoolVar(&flagNoYAMLAnchors, "no-yaml-anchors", false, "Reject yaml values files that use aliases, for untrusted input")
+	flags.StringVar(&flagDecryptCmd, "values-decrypt-cmd", "", "Pipe each values file through this command and parse its output instead")
+	flags.BoolVar(&flagSops, "sops", false, "Decrypt values files with sops before parsing them")
+	flags.StringVar(&flagMergeSemantics, "merge-semantics", "default", "How values files are merged: default or rfc7386 (json merge patch)")
+	flags.StringArrayVar(&flagResolve, "resolve", nil, "Resolve merge conflicts at a dotted path with max, min, sum, first or last (path:strategy, repeatable)")
+	flags.StringVar(&flagCPUProfile, "cpuprofile", "", "Write a cpu profile to the file given")
+	flags.StringVar(&flagMemProfile, "memprofile", "", "Write a memory profile to the file given")
+	flags.MarkHidden("cpuprofile")
+	flags.MarkHidden("memprofile")
+	rootCmd.Args = func(cmd *cobra.Command, args []string) error {
+		// A bundle brings its own values files
+		if len(flagBundle) != 0 {
+			return nil
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	}
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -53,7 +192,31 @@ func doTemplating(cmd *cobra.Command, args []string) error {
 	var byt []byte
 	var err error
 
-	if len(flagInput) != 0 {
+	stopProfiling, err := startProfiling(flagCPUProfile, flagMemProfile)
+	if err != nil {
+		return err
+	}
+	defer stopProfiling()
+
+	if len(flagBundle) != 0 {
+		if cmd.Flags().Changed("template") || len(flagInput) != 0 {
+			return errors.New("--bundle cannot be used with --template or --input")
+		}
+
+		b, err := openBundle(flagBundle)
+		if err != nil {
+			return err
+		}
+		defer b.Close()
+
+		byt, err = ioutil.ReadFile(b.template)
+		if err != nil {
+			return errors.Wrap(err, "failed to read bundle template")
+		}
+		args = append(b.values, args...)
+	} else if cmd.Flags().Changed("template") {
+		byt = []byte(flagTemplate)
+	} else if len(flagInput) != 0 {
 		byt, err = ioutil.ReadFile(flagInput)
 	} else {
 		byt, err = ioutil.ReadAll(os.Stdin)
@@ -62,68 +225,477 @@ func doTemplating(cmd *cobra.Command, args []string) error {
 		return errors.Wrap(err, "failed to read input")
 	}
 
-	data, err := readValuesFiles(args)
+	if len(flagInputEncoding) != 0 {
+		if byt, err = decodeToUTF8(flagInputEncoding, byt); err != nil {
+			return errors.Wrap(err, "failed to decode input")
+		}
+	}
+
+	strategies, err := parseResolveStrategies(flagResolve)
+	if err != nil {
+		return err
+	}
+
+	transforms, err := parseValueTransforms(flagTransformValues)
+	if err != nil {
+		return err
+	}
+
+	outputFormatters, err := parseFormatters(flagFormatByExt)
+	if err != nil {
+		return err
+	}
+
+	var maxOutputSize int64
+	if len(flagMaxOutputSize) != 0 {
+		if maxOutputSize, err = parseSize(flagMaxOutputSize); err != nil {
+			return errors.Wrap(err, "failed to parse --max-output-size")
+		}
+	}
+
+	switch flagMissingValues {
+	case "error", "empty", "skip":
+	default:
+		return errors.Errorf("unknown --missing-values mode %q", flagMissingValues)
+	}
+
+	if cmd.Flags().Changed("values-decrypt-cmd") && len(strings.Fields(flagDecryptCmd)) == 0 {
+		return errors.New("--values-decrypt-cmd must not be empty")
+	}
+
+	if flagSops && len(flagDecryptCmd) != 0 {
+		return errors.New("--sops and --values-decrypt-cmd cannot be used together")
+	}
+
+	switch flagMergeSemantics {
+	case "default", "rfc7386":
+	default:
+		return errors.Errorf("unknown --merge-semantics %q", flagMergeSemantics)
+	}
+
+	if flagPerFile {
+		if len(flagOutputDir) == 0 {
+			return errors.New("--per-file requires --output-dir")
+		}
+		if len(flagOutput) != 0 || len(flagDumpValues) != 0 || len(flagMergeReport) != 0 {
+			return errors.New("--per-file cannot be used with --output, --dump-values or --merge-report")
+		}
+	} else if len(flagOutputDir) != 0 {
+		return errors.New("--output-dir is only used with --per-file")
+	}
+
+	loaders, err := loadPlugins(flagLoaders)
 	if err != nil {
 		return err
 	}
 
-	tpl, err := template.New("").Funcs(sprig.TxtFuncMap()).Parse(string(byt))
+	opts := valuesOptions{
+		loaders:    loaders,
+		strategies: strategies,
+		sops:       flagSops,
+		decryptCmd: flagDecryptCmd,
+		missing:    flagMissingValues,
+		allowAWS:   flagAllowAWS,
+		lenient:    flagLenientYAML,
+		noAliases:  flagNoYAMLAnchors,
+		mergePatch: flagMergeSemantics == "rfc7386",
+	}
+	if len(flagMergeReport) != 0 {
+		opts.report = mergeReport{}
+	}
+
+	if flagTemplatePaths {
+		if args, err = renderValuesPaths(args); err != nil {
+			return err
+		}
+	}
+
+	var data interface{}
+	if !flagPerFile {
+		if data, err = readValuesFiles(args, opts); err != nil {
+			return err
+		}
+		data = transformValues(data, transforms)
+
+		if len(flagMergeReport) != 0 {
+			if err = opts.report.write(flagMergeReport); err != nil {
+				return err
+			}
+		}
+
+		if len(flagDumpValues) != 0 {
+			if err = dumpValues(flagDumpValues, flagDumpValuesFormat, data); err != nil {
+				return err
+			}
+		}
+	}
+
+	if flagStrictDelims {
+		if err = lintDelims(string(byt), "{{", "}}"); err != nil {
+			return errors.Wrap(err, "failed to lint template")
+		}
+	}
+
+	rend := &renderer{root: data}
+	state := newStateStore()
+
+	seed := flagSeed
+	if !cmd.Flags().Changed("seed") {
+		seed = time.Now().UnixNano()
+	}
+
+	tpl, err := template.New("").
+		Funcs(funcMap()).
+		Funcs(gitFuncs(flagAllowGit)).
+		Funcs(dotenvFuncs(flagDotenvUpper)).
+		Funcs(rend.funcs()).
+		Funcs(newSequences().funcs()).
+		Funcs(fakeFuncs(flagAllowFake, seed)).
+		Funcs(state.funcs()).
+		Parse(string(byt))
 	if err != nil {
 		return errors.Wrap(err, "failed to compile template")
 	}
+	rend.tpl = tpl
+
+	for _, define := range flagDefine {
+		idx := strings.IndexByte(define, '=')
+		if idx <= 0 {
+			return errors.Errorf("define %q must be in the form name=body", define)
+		}
 
-	output := &bytes.Buffer{}
-	if err = tpl.Execute(output, data); err != nil {
-		return errors.Wrap(err, "failed to execute template")
+		name, body := define[:idx], define[idx+1:]
+		if _, err = tpl.New(name).Parse(body); err != nil {
+			return errors.Wrapf(err, "failed to compile defined template %s", name)
+		}
 	}
 
-	if len(flagOutput) != 0 {
-		err = ioutil.WriteFile(flagOutput, output.Bytes(), 0664)
+	// render executes the template against data and post processes it,
+	// outputFile is only used to pick a formatter and may be empty.
+	render := func(data interface{}, outputFile string) ([]byte, error) {
+		output := &bytes.Buffer{}
+		var w io.Writer = output
+		if maxOutputSize != 0 {
+			w = &limitWriter{w: output, limit: maxOutputSize}
+		}
+
+		if err := tpl.Execute(w, data); err != nil {
+			return nil, errors.Wrap(err, "failed to execute template")
+		}
+
+		rendered, err := assembleSections(output.Bytes())
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to assemble sections")
+		}
+
+		if len(flagPlaceholder) != 0 {
+			rendered = bytes.Replace(rendered, []byte("<no value>"), []byte(flagPlaceholder), -1)
+		}
+
+		if len(flagCanonicalize) != 0 {
+			if rendered, err = canonicalize(flagCanonicalize, rendered); err != nil {
+				return nil, errors.Wrap(err, "failed to canonicalize output")
+			}
+		}
+
+		if len(outputFile) != 0 {
+			if rendered, err = outputFormatters.format(outputFile, rendered); err != nil {
+				return nil, err
+			}
+		}
+
+		if len(flagOutputEncoding) != 0 {
+			if rendered, err = encodeFromUTF8(flagOutputEncoding, rendered); err != nil {
+				return nil, errors.Wrap(err, "failed to encode output")
+			}
+		}
+
+		return rendered, nil
+	}
+
+	if flagPerFile {
+		// Check every name first so nothing is written if two collide
+		outputFiles := make([]string, len(args))
+		seen := make(map[string]string, len(args))
+		for i, file := range args {
+			outputFiles[i] = perFileOutput(flagOutputDir, file, flagInput)
+			if other, ok := seen[outputFiles[i]]; ok {
+				return errors.Errorf("--per-file would write both %s and %s to %s", other, file, outputFiles[i])
+			}
+			seen[outputFiles[i]] = file
+		}
+
+		if err = os.MkdirAll(flagOutputDir, 0755); err != nil {
+			return errors.Wrap(err, "failed to create output dir")
+		}
+
+		for i, file := range args {
+			data, err := readValuesFiles([]string{file}, opts)
+			if err != nil {
+				return err
+			}
+			data = transformValues(data, transforms)
+			rend.root = data
+			// Sequences are per render so each output numbers from the start
+			tpl.Funcs(newSequences().funcs())
+
+			outputFile := outputFiles[i]
+			output, err := render(data, outputFile)
+			if err != nil {
+				return errors.Wrap(err, file)
+			}
+
+			if err = writeFile(outputFile, output); err != nil {
+				return errors.Wrapf(err, "failed to write output for %s", file)
+			}
+		}
 	} else {
-		_, err = io.Copy(os.Stdout, output)
+		output, err := render(data, flagOutput)
+		if err != nil {
+			return err
+		}
+
+		if len(flagOutput) != 0 {
+			err = writeFile(flagOutput, output)
+		} else {
+			_, err = os.Stdout.Write(output)
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to write output")
+		}
 	}
 
-	if err != nil {
-		return errors.Wrap(err, "failed to write output")
+	if len(flagStateOutput) != 0 {
+		if err = state.write(flagStateOutput); err != nil {
+			return err
+		}
+	}
+
+	if len(flagManifest) != 0 {
+		if err = written.write(flagManifest); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func readValuesFiles(files []string) (interface{}, error) {
-	data := map[string]interface{}{}
+// perFileOutput names the --per-file output for valuesFile after it, with
+// the extension the template would have without a .tpl or .tmpl suffix
+// (eg. values/acme.yaml with config.json.tpl is written to dir/acme.json).
+// input is empty for --template, --bundle and stdin so there's no extension.
+func perFileOutput(dir, valuesFile, input string) string {
+	name := filepath.Base(valuesFile)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
 
-	for _, file := range files {
-		byt, err := ioutil.ReadFile(file)
+	input = strings.TrimSuffix(input, ".tpl")
+	input = strings.TrimSuffix(input, ".tmpl")
+
+	return filepath.Join(dir, name+filepath.Ext(input))
+}
+
+// renderValuesPaths executes each path as a template. The environment
+// variables are the data so both {{ .HOME }} and {{ env "HOME" }} work.
+func renderValuesPaths(paths []string) ([]string, error) {
+	environ := map[string]string{}
+	for _, kv := range os.Environ() {
+		if idx := strings.IndexByte(kv, '='); idx > 0 {
+			environ[kv[:idx]] = kv[idx+1:]
+		}
+	}
+
+	rendered := make([]string, len(paths))
+	for i, path := range paths {
+		tpl, err := template.New(path).Funcs(funcMap()).Parse(path)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to read values file")
+			return nil, errors.Wrapf(err, "failed to compile values path %s", path)
+		}
+
+		buf := &bytes.Buffer{}
+		if err = tpl.Execute(buf, environ); err != nil {
+			return nil, errors.Wrapf(err, "failed to execute values path %s", path)
 		}
+		rendered[i] = buf.String()
+	}
+
+	return rendered, nil
+}
 
+// valuesOptions controls how readValuesFiles loads and merges values files.
+type valuesOptions struct {
+	loaders    map[string]loaderFunc
+	strategies resolveStrategies
+	sops       bool
+	decryptCmd string
+	// missing is one of error, empty or skip and decides
+	// what happens to values files that don't exist.
+	missing  string
+	allowAWS bool
+	// lenient parses yaml files with parseLenientYAML.
+	lenient   bool
+	noAliases bool
+	// mergePatch merges files with mergePatch instead of mergeMaps.
+	mergePatch bool
+	// report is filled in with the source of each key if it's not nil.
+	report mergeReport
+}
+
+// writeFile writes byt to file. Regular files are created or truncated
+// as usual and recorded for --manifest, but if file is a named pipe,
+// device or socket it is simply opened and written to since truncating or
+// setting permissions on those makes no sense.
+func writeFile(file string, byt []byte) error {
+	info, err := os.Stat(file)
+	if err != nil || info.Mode().IsRegular() {
+		if err = ioutil.WriteFile(file, byt, 0664); err != nil {
+			return err
+		}
+		written.add(file, byt)
+		return nil
+	}
+
+	f, err := os.OpenFile(file, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(byt); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func readValuesFiles(files []string, opts valuesOptions) (interface{}, error) {
+	data := map[string]interface{}{}
+
+	for _, file := range files {
 		var incomingData map[string]interface{}
-		switch filepath.Ext(file) {
-		case ".yaml", ".yml":
-			if err = yaml.Unmarshal(byt, &incomingData); err != nil {
-				return nil, errors.Wrapf(err, "failed to parse values file %s as yaml", file)
+		var err error
+
+		if load, ok := opts.loaders[uriScheme(file)]; ok {
+			if incomingData, err = load(file); err != nil {
+				err = errors.Wrapf(err, "failed to load values from %s", file)
 			}
-			incomingData = convertToMapStringIntf(incomingData).(map[string]interface{})
-		default:
-			if err = json.Unmarshal(byt, &incomingData); err != nil {
-				return nil, errors.Wrapf(err, "failed to parse values file %s as json", file)
+		} else if strings.HasPrefix(file, ssmScheme) {
+			if !opts.allowAWS {
+				return nil, errors.Errorf("reading values from %s requires --allow-aws", file)
 			}
+			incomingData, err = readSSMParameters(strings.TrimPrefix(file, ssmScheme))
+		} else {
+			incomingData, err = readValuesFile(file, opts)
 		}
-
-		data, err = mergeMaps(data, incomingData)
 		if err != nil {
 			return nil, err
 		}
+
+		if opts.mergePatch {
+			data = mergePatch(data, incomingData).(map[string]interface{})
+		} else if data, err = mergeMaps(data, incomingData, opts.strategies); err != nil {
+			return nil, err
+		}
+
+		if opts.report != nil {
+			opts.report.record(file, incomingData, data)
+		}
 	}
 
 	return data, nil
 }
 
+// readValuesFile reads and parses a single values file. A nil map is
+// returned if the file is missing and opts say to skip it.
+func readValuesFile(file string, opts valuesOptions) (map[string]interface{}, error) {
+	var byt []byte
+	var err error
+
+	missing := false
+	if opts.missing != "error" {
+		_, err = os.Stat(file)
+		missing = os.IsNotExist(err)
+	}
+
+	switch {
+	case missing && opts.missing == "skip":
+		return nil, nil
+	case missing:
+		byt, err = []byte("{}"), nil
+	case opts.sops:
+		byt, err = decryptSops(file)
+	default:
+		byt, err = ioutil.ReadFile(file)
+		err = errors.Wrap(err, "failed to read values file")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts.decryptCmd) != 0 && !missing {
+		if byt, err = runFilter(opts.decryptCmd, byt); err != nil {
+			return nil, errors.Wrapf(err, "failed to decrypt values file %s", file)
+		}
+	}
+
+	var incomingData map[string]interface{}
+	switch filepath.Ext(file) {
+	case ".yaml", ".yml":
+		if opts.noAliases {
+			// Check the tab expanded yaml the lenient parser will read
+			checked := byt
+			if opts.lenient {
+				checked, _ = expandYAMLTabs(byt)
+			}
+			if err = checkYAMLAliases(file, checked); err != nil {
+				return nil, err
+			}
+		}
+		if opts.lenient {
+			return parseLenientYAML(file, byt)
+		}
+		if err = yaml.Unmarshal(byt, &incomingData); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse values file %s as yaml", file)
+		}
+		incomingData = convertToMapStringIntf(incomingData).(map[string]interface{})
+	default:
+		if err = json.Unmarshal(byt, &incomingData); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse values file %s as json", file)
+		}
+	}
+
+	return incomingData, nil
+}
+
+// dumpValues writes the merged values out to file in the given format
+// so there's a record of what the template was executed against.
+func dumpValues(file, format string, data interface{}) error {
+	var byt []byte
+	var err error
+
+	switch format {
+	case "json":
+		byt, err = json.MarshalIndent(data, "", "  ")
+		byt = append(byt, '\n')
+	case "yaml", "yml":
+		byt, err = yaml.Marshal(data)
+	default:
+		return errors.Errorf("unknown dump values format %q", format)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal values")
+	}
+
+	if err = writeFile(file, byt); err != nil {
+		return errors.Wrap(err, "failed to write values dump")
+	}
+
+	return nil
+}
+
 // convertToMapStringIntf takes a object and recursively attempts to
-// convert any maps in it of type map[interface{}]interface{} to
-// map[string]interface{}, all other values are simply returned.
+// convert any maps in it (including those inside lists) of type
+// map[interface{}]interface{} to map[string]interface{}, all other
+// values are simply returned.
 func convertToMapStringIntf(value interface{}) interface{} {
 	switch m := value.(type) {
 	case map[string]interface{}:
@@ -137,6 +709,11 @@ func convertToMapStringIntf(value interface{}) interface{} {
 			newMap[k.(string)] = convertToMapStringIntf(v)
 		}
 		return newMap
+	case []interface{}:
+		for i, v := range m {
+			m[i] = convertToMapStringIntf(v)
+		}
+		return m
 	default:
 		return value
 	}
@@ -144,11 +721,95 @@ func convertToMapStringIntf(value interface{}) interface{} {
 
 var strMapType = reflect.TypeOf(map[string]interface{}{})
 
+// resolveStrategies maps dotted key paths to the strategy used to
+// resolve a conflict between two values at that path when merging.
+type resolveStrategies map[string]string
+
+// parseResolveStrategies parses the path:strategy pairs given to --resolve.
+func parseResolveStrategies(pairs []string) (resolveStrategies, error) {
+	strategies := make(resolveStrategies, len(pairs))
+	for _, pair := range pairs {
+		idx := strings.LastIndexByte(pair, ':')
+		if idx <= 0 {
+			return nil, errors.Errorf("resolve %q must be in the form path:strategy", pair)
+		}
+
+		path, strategy := pair[:idx], pair[idx+1:]
+		switch strategy {
+		case "max", "min", "sum", "first", "last":
+		default:
+			return nil, errors.Errorf("resolve %q has unknown strategy %q", pair, strategy)
+		}
+
+		strategies[path] = strategy
+	}
+
+	return strategies, nil
+}
+
+// resolve picks the value to keep at a conflicting key according to strategy.
+// It returns false if strategy doesn't apply to the values.
+func resolve(strategy string, dst, src reflect.Value) (reflect.Value, bool) {
+	switch strategy {
+	case "first":
+		return dst, true
+	case "last":
+		return src, true
+	}
+
+	dstNum, dstOk := toFloat(dst)
+	srcNum, srcOk := toFloat(src)
+	if !dstOk || !srcOk {
+		return reflect.Value{}, false
+	}
+
+	switch strategy {
+	case "max":
+		if dstNum >= srcNum {
+			return dst, true
+		}
+		return src, true
+	case "min":
+		if dstNum <= srcNum {
+			return dst, true
+		}
+		return src, true
+	case "sum":
+		if isInt(dst) && isInt(src) {
+			return reflect.ValueOf(int(dst.Int() + src.Int())), true
+		}
+		return reflect.ValueOf(dstNum + srcNum), true
+	}
+
+	return reflect.Value{}, false
+}
+
+func isInt(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func toFloat(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
 // mergeMaps takes two map[string]interface{}
 // and attempts to merge them into dst. Keys that exist
-// in dst will be overwritten with values from src.
-func mergeMaps(dst, src interface{}) (map[string]interface{}, error) {
-	m, err := mergeMapsHelper(reflect.ValueOf(dst), reflect.ValueOf(src))
+// in dst will be overwritten with values from src unless
+// strategies says otherwise for the key's path.
+func mergeMaps(dst, src interface{}, strategies resolveStrategies) (map[string]interface{}, error) {
+	m, err := mergeMapsHelper("", reflect.ValueOf(dst), reflect.ValueOf(src), strategies)
 	if err != nil {
 		return nil, err
 	}
@@ -156,7 +817,7 @@ func mergeMaps(dst, src interface{}) (map[string]interface{}, error) {
 	return m.(map[string]interface{}), nil
 }
 
-func mergeMapsHelper(dst, src reflect.Value) (interface{}, error) {
+func mergeMapsHelper(path string, dst, src reflect.Value, strategies resolveStrategies) (interface{}, error) {
 	if dst.Type() != strMapType {
 		return nil, errors.New("dst was not a map[string]interface{}")
 	}
@@ -164,18 +825,57 @@ func mergeMapsHelper(dst, src reflect.Value) (interface{}, error) {
 		return nil, errors.New("src was not a map[string]interface{}")
 	}
 
-	for _, key := range src.MapKeys() {
+	// Appends go last so key<< adds to key even when both are in src
+	keys := src.MapKeys()
+	sort.SliceStable(keys, func(i, j int) bool {
+		return !isAppendKey(keys[i].String()) && isAppendKey(keys[j].String())
+	})
+
+	for _, key := range keys {
 		srcValue := src.MapIndex(key).Elem()
+
+		appending := isAppendKey(key.String())
+		if appending {
+			key = reflect.ValueOf(strings.TrimSuffix(key.String(), appendSuffix))
+		}
 		dstValue := dst.MapIndex(key)
+
+		keyPath := key.String()
+		if len(path) != 0 {
+			keyPath = path + "." + keyPath
+		}
+
+		if appending {
+			value, err := appendValue(dstValue, srcValue)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to append to %s", keyPath)
+			}
+			dst.SetMapIndex(key, value)
+			continue
+		}
+
+		// A null in src replaces whatever dst had, strategies don't apply
+		if !srcValue.IsValid() {
+			dst.SetMapIndex(key, src.MapIndex(key))
+			continue
+		}
+
 		srcType := srcValue.Type()
 		var dstType reflect.Type
 
-		if dstValue.IsValid() {
+		if dstValue.IsValid() && dstValue.Elem().IsValid() {
 			dstValue = dstValue.Elem()
 			dstType = dstValue.Type()
 
+			if strategy, ok := strategies[keyPath]; ok {
+				if value, ok := resolve(strategy, dstValue, srcValue); ok {
+					dst.SetMapIndex(key, value)
+					continue
+				}
+			}
+
 			if srcType == strMapType && dstType == strMapType {
-				intf, err := mergeMapsHelper(dstValue, srcValue)
+				intf, err := mergeMapsHelper(keyPath, dstValue, srcValue, strategies)
 				if err != nil {
 					return nil, err
 				}
@@ -185,8 +885,62 @@ func mergeMapsHelper(dst, src reflect.Value) (interface{}, error) {
 			}
 		}
 
+		// New maps still need their own key<< operators applied
+		if srcType == strMapType {
+			intf, err := mergeMapsHelper(keyPath, reflect.ValueOf(map[string]interface{}{}), srcValue, strategies)
+			if err != nil {
+				return nil, err
+			}
+
+			dst.SetMapIndex(key, reflect.ValueOf(intf))
+			continue
+		}
+
 		dst.SetMapIndex(key, srcValue)
 	}
 
 	return dst.Interface(), nil
 }
+
+// appendSuffix on a key in a values file appends its value to the value
+// already merged under the key instead of replacing it.
+const appendSuffix = "<<"
+
+func isAppendKey(key string) bool {
+	return len(key) > len(appendSuffix) && strings.HasSuffix(key, appendSuffix)
+}
+
+// appendValue appends src to dst for a key<< key. Strings are concatenated,
+// lists are joined and anything else is added to the end of a list. If dst
+// is missing or null src is used as it is, a null src leaves dst alone.
+func appendValue(dst, src reflect.Value) (reflect.Value, error) {
+	if dst.IsValid() {
+		dst = dst.Elem()
+	}
+	if !src.IsValid() {
+		if !dst.IsValid() {
+			return reflect.Zero(strMapType.Elem()), nil
+		}
+		return dst, nil
+	}
+	if !dst.IsValid() {
+		return src, nil
+	}
+
+	switch d := dst.Interface().(type) {
+	case string:
+		if s, ok := src.Interface().(string); ok {
+			return reflect.ValueOf(d + s), nil
+		}
+	case []interface{}:
+		list := append([]interface{}{}, d...)
+		if s, ok := src.Interface().([]interface{}); ok {
+			list = append(list, s...)
+		} else {
+			list = append(list, src.Interface())
+		}
+		return reflect.ValueOf(list), nil
+	}
+
+	return reflect.Value{}, errors.Errorf("cannot append a %s to a %s", src.Type(), dst.Type())
+}
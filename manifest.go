@@ -0,0 +1,66 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// written records every regular file writeFile creates or truncates so
+// --manifest can list them.
+var written = &manifest{}
+
+type manifestEntry struct {
+	Path   string `json:"path"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+type manifest struct {
+	mut     sync.Mutex
+	entries []manifestEntry
+}
+
+// add records file, replacing an earlier entry if it was written twice.
+func (m *manifest) add(file string, byt []byte) {
+	hash := sha256.Sum256(byt)
+	entry := manifestEntry{
+		Path:   filepath.Clean(file),
+		Size:   len(byt),
+		SHA256: hex.EncodeToString(hash[:]),
+	}
+
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	for i, e := range m.entries {
+		if e.Path == entry.Path {
+			m.entries[i] = entry
+			return
+		}
+	}
+	m.entries = append(m.entries, entry)
+}
+
+// write saves the entries to file as a json array in the order the files
+// were first written. The manifest itself is not included.
+func (m *manifest) write(file string) error {
+	m.mut.Lock()
+	entries := append([]manifestEntry{}, m.entries...)
+	m.mut.Unlock()
+
+	byt, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal manifest")
+	}
+
+	if err = writeFile(file, append(byt, '\n')); err != nil {
+		return errors.Wrap(err, "failed to write manifest")
+	}
+
+	return nil
+}
@@ -0,0 +1,26 @@
+package main
+
+// mergePatch applies patch to target following the JSON Merge Patch rules
+// of RFC7386: maps are merged recursively, a null deletes the key and any
+// other value replaces the target. target may be modified.
+func mergePatch(target, patch interface{}) interface{} {
+	patchMap, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetMap, ok := target.(map[string]interface{})
+	if !ok {
+		targetMap = make(map[string]interface{}, len(patchMap))
+	}
+
+	for k, v := range patchMap {
+		if v == nil {
+			delete(targetMap, k)
+			continue
+		}
+		targetMap[k] = mergePatch(targetMap[k], v)
+	}
+
+	return targetMap
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// toIntOr converts value to an int, it may be a number or a string holding
+// one. If value can't be converted, or isn't a whole number, def is returned.
+func toIntOr(value interface{}, def int) int {
+	f, ok := parseNumber(value)
+	if !ok || f != math.Trunc(f) || f > math.MaxInt64 || f < math.MinInt64 {
+		return def
+	}
+
+	return int(f)
+}
+
+// toFloatOr converts value to a float64, it may be a number or a string
+// holding one. If value can't be converted def is returned.
+func toFloatOr(value interface{}, def float64) float64 {
+	f, ok := parseNumber(value)
+	if !ok {
+		return def
+	}
+
+	return f
+}
+
+func parseNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return 0, false
+		}
+		return f, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"github.com/nyaruka/phonenumbers"
+	"github.com/pkg/errors"
+)
+
+// formatPhone parses number as a phone number for the region given (an
+// ISO 3166 country code such as US, used when the number has no leading +)
+// and returns it in E.164 form. Invalid numbers are an error.
+func formatPhone(region, number string) (string, error) {
+	num, err := phonenumbers.Parse(number, region)
+	if err != nil {
+		return "", errors.Wrapf(err, "formatPhone: failed to parse %q", number)
+	}
+	if !phonenumbers.IsValidNumber(num) {
+		return "", errors.Errorf("formatPhone: %q is not a valid phone number for region %s", number, region)
+	}
+
+	return phonenumbers.Format(num, phonenumbers.E164), nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"plugin"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// loaderFunc loads values from a uri, it is the signature of the Load
+// symbol that loader plugins must export.
+type loaderFunc func(uri string) (map[string]interface{}, error)
+
+// loadPlugins opens the loader plugins given as scheme=path.so pairs and
+// returns their Load functions by scheme.
+func loadPlugins(specs []string) (map[string]loaderFunc, error) {
+	loaders := make(map[string]loaderFunc, len(specs))
+	for _, spec := range specs {
+		idx := strings.IndexByte(spec, '=')
+		if idx <= 0 || idx == len(spec)-1 {
+			return nil, errors.Errorf("loader %q must be in the form scheme=path", spec)
+		}
+		scheme, path := spec[:idx], spec[idx+1:]
+
+		p, err := plugin.Open(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to open loader plugin %s", path)
+		}
+
+		sym, err := p.Lookup("Load")
+		if err != nil {
+			return nil, errors.Wrapf(err, "loader plugin %s", path)
+		}
+
+		switch load := sym.(type) {
+		case func(string) (map[string]interface{}, error):
+			loaders[scheme] = load
+		case *func(string) (map[string]interface{}, error):
+			loaders[scheme] = *load
+		default:
+			return nil, errors.Errorf("loader plugin %s: Load is a %T, not a func(string) (map[string]interface{}, error)", path, sym)
+		}
+	}
+
+	return loaders, nil
+}
+
+// uriScheme returns the scheme of a scheme://... argument or an empty
+// string if it doesn't have one.
+func uriScheme(arg string) string {
+	idx := strings.Index(arg, "://")
+	if idx <= 0 {
+		return ""
+	}
+	return arg[:idx]
+}
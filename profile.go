@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+
+	"github.com/pkg/errors"
+)
+
+// startProfiling starts a cpu profile if cpuFile is set. The returned func
+// stops it and writes a heap profile if memFile is set, errors from that
+// point are printed to stderr so they don't mask the render's own error.
+func startProfiling(cpuFile, memFile string) (func(), error) {
+	var cpu *os.File
+	if len(cpuFile) != 0 {
+		var err error
+		if cpu, err = os.Create(cpuFile); err != nil {
+			return nil, errors.Wrap(err, "failed to create cpu profile")
+		}
+		if err = pprof.StartCPUProfile(cpu); err != nil {
+			cpu.Close()
+			return nil, errors.Wrap(err, "failed to start cpu profile")
+		}
+	}
+
+	return func() {
+		if cpu != nil {
+			pprof.StopCPUProfile()
+			if err := cpu.Close(); err != nil {
+				fmt.Fprintln(os.Stderr, "failed to write cpu profile:", err)
+			}
+		}
+
+		if len(memFile) != 0 {
+			if err := writeHeapProfile(memFile); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+	}, nil
+}
+
+func writeHeapProfile(file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return errors.Wrap(err, "failed to create memory profile")
+	}
+
+	runtime.GC()
+	if err = pprof.WriteHeapProfile(f); err != nil {
+		f.Close()
+		return errors.Wrap(err, "failed to write memory profile")
+	}
+
+	return errors.Wrap(f.Close(), "failed to write memory profile")
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// renderer provides the render template function which executes template
+// strings stored in the values. tpl must be set to the main template before
+// it is executed so rendered strings can use the same functions and named
+// templates.
+type renderer struct {
+	root interface{}
+	tpl  *template.Template
+}
+
+func (r *renderer) funcs() template.FuncMap {
+	return template.FuncMap{
+		"render": r.render,
+	}
+}
+
+// render looks up the template string at the dotted keyPath in the root
+// values and executes it against data.
+func (r *renderer) render(keyPath string, data interface{}) (string, error) {
+	root, ok := r.root.(map[string]interface{})
+	if !ok {
+		return "", errors.New("render: values are not a map")
+	}
+
+	value, ok := lookupPath(root, keyPath)
+	if !ok {
+		return "", errors.Errorf("render: key %q does not exist", keyPath)
+	}
+	text, ok := value.(string)
+	if !ok {
+		return "", errors.Errorf("render: key %q is a %T, not a string", keyPath, value)
+	}
+
+	tpl, err := r.tpl.Clone()
+	if err != nil {
+		return "", errors.Wrap(err, "render")
+	}
+	if tpl, err = tpl.New(keyPath).Parse(text); err != nil {
+		return "", errors.Wrapf(err, "render: failed to compile %s", keyPath)
+	}
+
+	buf := &bytes.Buffer{}
+	if err = tpl.Execute(buf, data); err != nil {
+		return "", errors.Wrapf(err, "render: failed to execute %s", keyPath)
+	}
+
+	return buf.String(), nil
+}
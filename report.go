@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// mergeReport maps the dotted path of every leaf in the merged values to
+// where its final value came from. Lists and empty maps count as leaves.
+type mergeReport map[string]*reportEntry
+
+type reportEntry struct {
+	// Source is the values file the final value came from. When --resolve
+	// keeps an earlier value, or sums several, it stays the earlier file.
+	// For key<< appends it is the last file that appended.
+	Source string `json:"source"`
+	// Overridden is true when more than one file set the key.
+	Overridden bool `json:"overridden"`
+	// Sources lists every file that set the key, in merge order.
+	Sources []string `json:"sources"`
+}
+
+// record notes source for every leaf in incoming. merged is the values
+// after incoming was merged in.
+func (r mergeReport) record(source string, incoming, merged map[string]interface{}) {
+	r.recordHelper("", source, incoming, merged)
+}
+
+func (r mergeReport) recordHelper(path, source string, incoming, merged map[string]interface{}) {
+	// Visit keys in the order mergeMapsHelper applies them
+	keys := make([]string, 0, len(incoming))
+	for k := range incoming {
+		keys = append(keys, k)
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return !isAppendKey(keys[i]) && isAppendKey(keys[j])
+	})
+
+	for _, k := range keys {
+		v := incoming[k]
+
+		mergedValue, ok := merged[k]
+		appended := false
+		if !ok && isAppendKey(k) {
+			k = strings.TrimSuffix(k, appendSuffix)
+			mergedValue, ok = merged[k]
+			appended = true
+		}
+
+		keyPath := k
+		if len(path) != 0 {
+			keyPath = path + "." + k
+		}
+		if !ok {
+			// Deleted by a merge patch
+			delete(r, keyPath)
+			r.forget(keyPath)
+			continue
+		}
+
+		child, isMap := v.(map[string]interface{})
+		mergedChild, mergedIsMap := mergedValue.(map[string]interface{})
+		if isMap && mergedIsMap && len(mergedChild) != 0 {
+			delete(r, keyPath)
+			r.recordHelper(keyPath, source, child, mergedChild)
+			continue
+		}
+
+		entry, ok := r[keyPath]
+		if !ok {
+			entry = &reportEntry{}
+			r[keyPath] = entry
+		}
+		// A scalar replacing a map also overrides everything under it
+		for _, e := range r.forget(keyPath) {
+			entry.Sources = appendMissing(entry.Sources, e.Sources...)
+		}
+
+		if len(entry.Sources) == 0 || appended || reflect.DeepEqual(v, mergedValue) {
+			entry.Source = source
+		}
+		entry.Sources = appendMissing(entry.Sources, source)
+		entry.Overridden = len(entry.Sources) > 1
+	}
+}
+
+// forget removes and returns the entries below path.
+func (r mergeReport) forget(path string) []*reportEntry {
+	var removed []*reportEntry
+	for p, entry := range r {
+		if strings.HasPrefix(p, path+".") {
+			removed = append(removed, entry)
+			delete(r, p)
+		}
+	}
+	return removed
+}
+
+func (r mergeReport) write(file string) error {
+	byt, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal merge report")
+	}
+
+	if err = writeFile(file, append(byt, '\n')); err != nil {
+		return errors.Wrap(err, "failed to write merge report")
+	}
+
+	return nil
+}
+
+func appendMissing(list []string, elems ...string) []string {
+	for _, elem := range elems {
+		found := false
+		for _, have := range list {
+			if have == elem {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, elem)
+		}
+	}
+	return list
+}
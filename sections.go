@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Sections are collected after the template has executed. The section
+// functions print markers into the output which assembleSections then
+// removes, moving the text between them to wherever renderSections was
+// called. This way output can be captured with a plain
+// {{ sectionStart "imports" }}...{{ sectionEnd }} and renderSections may
+// appear before the sections it renders.
+const (
+	sectionMarker = "\x00txtplate-section:"
+	sectionSep    = "\x01"
+)
+
+var sectionMarkers = regexp.MustCompile("\x00txtplate-section:(start|end|render):([^\x00]*)\x00")
+
+// sectionStart starts capturing output into the section name until
+// sectionEnd.
+func sectionStart(name string) (string, error) {
+	if err := checkSectionName(name); err != nil {
+		return "", errors.Wrap(err, "sectionStart")
+	}
+	return sectionMarker + "start:" + name + "\x00", nil
+}
+
+// sectionEnd stops capturing output started by sectionStart.
+func sectionEnd() string {
+	return sectionMarker + "end:\x00"
+}
+
+// sectionAppend adds text to the section name.
+func sectionAppend(name, text string) (string, error) {
+	start, err := sectionStart(name)
+	if err != nil {
+		return "", errors.Wrap(err, "sectionAppend")
+	}
+	return start + text + sectionEnd(), nil
+}
+
+// renderSections is replaced by the named sections in the order given.
+// Sections nothing was added to are empty.
+func renderSections(names ...string) (string, error) {
+	for _, name := range names {
+		if err := checkSectionName(name); err != nil {
+			return "", errors.Wrap(err, "renderSections")
+		}
+	}
+	return sectionMarker + "render:" + strings.Join(names, sectionSep) + "\x00", nil
+}
+
+func checkSectionName(name string) error {
+	if len(name) == 0 || strings.ContainsAny(name, "\x00"+sectionSep) {
+		return errors.Errorf("invalid section name %q", name)
+	}
+	return nil
+}
+
+// assembleSections removes the section markers from output, collecting the
+// text between sectionStart and sectionEnd and writing it out where
+// renderSections was called.
+func assembleSections(output []byte) ([]byte, error) {
+	if !bytes.Contains(output, []byte(sectionMarker)) {
+		return output, nil
+	}
+
+	sections := map[string]*bytes.Buffer{}
+	body := &bytes.Buffer{}
+	current := ""
+	pos := 0
+
+	for _, m := range sectionMarkers.FindAllSubmatchIndex(output, -1) {
+		text := output[pos:m[0]]
+		if len(current) != 0 {
+			sections[current].Write(text)
+		} else {
+			body.Write(text)
+		}
+		pos = m[1]
+
+		kind, name := string(output[m[2]:m[3]]), string(output[m[4]:m[5]])
+		switch kind {
+		case "start":
+			if len(current) != 0 {
+				return nil, errors.Errorf("section %q started inside section %q", name, current)
+			}
+			current = name
+			if sections[name] == nil {
+				sections[name] = &bytes.Buffer{}
+			}
+		case "end":
+			if len(current) == 0 {
+				return nil, errors.New("sectionEnd called without a sectionStart")
+			}
+			current = ""
+		case "render":
+			if len(current) != 0 {
+				return nil, errors.Errorf("renderSections called inside section %q", current)
+			}
+			body.Write(output[m[0]:m[1]])
+		}
+	}
+	if len(current) != 0 {
+		return nil, errors.Errorf("section %q is never ended", current)
+	}
+	body.Write(output[pos:])
+
+	return sectionMarkers.ReplaceAllFunc(body.Bytes(), func(marker []byte) []byte {
+		m := sectionMarkers.FindSubmatch(marker)
+		var rendered []byte
+		for _, name := range strings.Split(string(m[2]), sectionSep) {
+			if section := sections[name]; section != nil {
+				rendered = append(rendered, section.Bytes()...)
+			}
+		}
+		return rendered
+	}), nil
+}
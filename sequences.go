@@ -0,0 +1,45 @@
+package main
+
+import (
+	"sync"
+	"text/template"
+)
+
+// sequences holds the named counters behind nextID and nextIDFrom for
+// a single render. The ids handed out depend on the order the template
+// calls them in, so an id inside of a range depends on its iteration.
+type sequences struct {
+	mut  sync.Mutex
+	next map[string]int
+}
+
+func newSequences() *sequences {
+	return &sequences{next: make(map[string]int)}
+}
+
+func (s *sequences) funcs() template.FuncMap {
+	return template.FuncMap{
+		"nextID":     s.nextID,
+		"nextIDFrom": s.nextIDFrom,
+	}
+}
+
+// nextID returns the next id in the named sequence, starting at 1.
+func (s *sequences) nextID(name string) int {
+	return s.nextIDFrom(name, 1)
+}
+
+// nextIDFrom returns the next id in the named sequence. If this is the
+// first use of the sequence it starts at start, otherwise start is ignored.
+func (s *sequences) nextIDFrom(name string, start int) int {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	id, ok := s.next[name]
+	if !ok {
+		id = start
+	}
+	s.next[name] = id + 1
+
+	return id
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var shellName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// shellAssign renders NAME='value' with value single quoted so nothing in
+// it is expanded by the shell. Embedded single quotes close the quoting,
+// are backslash escaped and reopen it. nil values become an empty string.
+func shellAssign(name string, value interface{}) (string, error) {
+	if !shellName.MatchString(name) {
+		return "", errors.Errorf("shellAssign: %q is not a valid variable name", name)
+	}
+
+	str := ""
+	if value != nil {
+		str = fmt.Sprint(value)
+	}
+
+	return name + "=" + shellQuote(str), nil
+}
+
+func shellQuote(str string) string {
+	return "'" + strings.Replace(str, "'", `'\''`, -1) + "'"
+}
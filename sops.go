@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// decryptSops decrypts file with the sops binary and returns the
+// plaintext. sops does its own key discovery (age, pgp, kms etc.)
+// so the usual SOPS_* environment variables and config files apply.
+func decryptSops(file string) ([]byte, error) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+	cmd := exec.Command("sops", "--decrypt", file)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); len(msg) != 0 {
+			return nil, errors.Errorf("failed to decrypt values file %s with sops: %s", file, msg)
+		}
+		return nil, errors.Wrapf(err, "failed to decrypt values file %s with sops", file)
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// stateStore accumulates values from templates under named keys so that
+// an aggregate (eg. an index of what was generated) can be written out
+// once rendering is done. It is safe for concurrent use.
+type stateStore struct {
+	mut    sync.Mutex
+	values map[string][]interface{}
+}
+
+func newStateStore() *stateStore {
+	return &stateStore{values: make(map[string][]interface{})}
+}
+
+func (s *stateStore) funcs() template.FuncMap {
+	return template.FuncMap{
+		"stateAppend": s.stateAppend,
+		"stateGet":    s.stateGet,
+	}
+}
+
+// stateAppend adds value to the list under key. It returns an empty string
+// so it can be called from a template without producing output.
+func (s *stateStore) stateAppend(key string, value interface{}) string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.values[key] = append(s.values[key], value)
+	return ""
+}
+
+// stateGet returns a copy of the list under key.
+func (s *stateStore) stateGet(key string) []interface{} {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	list := make([]interface{}, len(s.values[key]))
+	copy(list, s.values[key])
+	return list
+}
+
+// write saves the whole store to file as a json object of lists.
+func (s *stateStore) write(file string) error {
+	s.mut.Lock()
+	byt, err := json.MarshalIndent(s.values, "", "  ")
+	s.mut.Unlock()
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal state")
+	}
+
+	if err = writeFile(file, append(byt, '\n')); err != nil {
+		return errors.Wrap(err, "failed to write state output")
+	}
+
+	return nil
+}
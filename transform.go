@@ -0,0 +1,73 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// valueTransform is a regex substitution given to --transform-values.
+type valueTransform struct {
+	re   *regexp.Regexp
+	repl string
+}
+
+// parseValueTransforms parses pattern=replacement pairs. The pattern ends at
+// the first = so to match a literal = in it use \x3d.
+func parseValueTransforms(pairs []string) ([]valueTransform, error) {
+	transforms := make([]valueTransform, 0, len(pairs))
+	for _, pair := range pairs {
+		idx := strings.IndexByte(pair, '=')
+		if idx <= 0 {
+			return nil, errors.Errorf("transform %q must be in the form pattern=replacement", pair)
+		}
+
+		re, err := regexp.Compile(pair[:idx])
+		if err != nil {
+			return nil, errors.Wrapf(err, "transform %q has an invalid pattern", pair)
+		}
+		transforms = append(transforms, valueTransform{re: re, repl: pair[idx+1:]})
+	}
+
+	return transforms, nil
+}
+
+// transformValues applies each transform in order to every string in value.
+func transformValues(value interface{}, transforms []valueTransform) interface{} {
+	for _, t := range transforms {
+		value = replaceTree(value, t.re, t.repl)
+	}
+	return value
+}
+
+// regexReplaceTree returns a copy of data with every string value replaced
+// as sprig's regexReplaceAll would. Map keys are left alone.
+func regexReplaceTree(pattern, repl string, data interface{}) (interface{}, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, errors.Wrap(err, "regexReplaceTree")
+	}
+	return replaceTree(data, re, repl), nil
+}
+
+func replaceTree(value interface{}, re *regexp.Regexp, repl string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return re.ReplaceAllString(v, repl)
+	case map[string]interface{}:
+		newMap := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			newMap[k] = replaceTree(elem, re, repl)
+		}
+		return newMap
+	case []interface{}:
+		newSlice := make([]interface{}, len(v))
+		for i, elem := range v {
+			newSlice[i] = replaceTree(elem, re, repl)
+		}
+		return newSlice
+	default:
+		return value
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// treeView draws nested maps and lists as a tree with ├── and └── branches.
+// Map keys are sorted, list elements are labelled by index and scalars are
+// printed beside their key. A scalar on its own is returned as it is.
+func treeView(value interface{}) string {
+	if !isTreeBranch(value) {
+		return treeLeaf(value)
+	}
+
+	buf := &strings.Builder{}
+	writeTree(buf, "", value)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func writeTree(buf *strings.Builder, indent string, value interface{}) {
+	var labels []string
+	var children []interface{}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		labels = make([]string, 0, len(v))
+		for k := range v {
+			labels = append(labels, k)
+		}
+		sort.Strings(labels)
+		for _, k := range labels {
+			children = append(children, v[k])
+		}
+	case []interface{}:
+		for i, elem := range v {
+			labels = append(labels, "["+strconv.Itoa(i)+"]")
+			children = append(children, elem)
+		}
+	}
+
+	for i, label := range labels {
+		branch, next := "├── ", "│   "
+		if i == len(labels)-1 {
+			branch, next = "└── ", "    "
+		}
+
+		child := children[i]
+		if isTreeBranch(child) {
+			fmt.Fprintf(buf, "%s%s%s\n", indent, branch, label)
+			writeTree(buf, indent+next, child)
+			continue
+		}
+		fmt.Fprintf(buf, "%s%s%s: %s\n", indent, branch, label, treeLeaf(child))
+	}
+}
+
+// isTreeBranch reports whether value has children to draw.
+func isTreeBranch(value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return len(v) != 0
+	case []interface{}:
+		return len(v) != 0
+	}
+	return false
+}
+
+func treeLeaf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "{}"
+	case []interface{}:
+		return "[]"
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// mutuallyExclusive fails when more than one of values is set. A value is
+// set unless it's nil, an empty string or an empty map or list, so zero and
+// false count as set. It returns an empty string so it prints nothing.
+func mutuallyExclusive(values ...interface{}) (string, error) {
+	if len(values) < 2 {
+		return "", errors.New("mutuallyExclusive: needs at least two values")
+	}
+
+	set, _ := splitSet(values)
+	if len(set) > 1 {
+		return "", errors.Errorf("mutuallyExclusive: at most one value may be set, set: %s", describeArgs(set))
+	}
+	return "", nil
+}
+
+// requiredTogether fails when some but not all of values are set, see
+// mutuallyExclusive for what counts as set. It returns an empty string so
+// it prints nothing.
+func requiredTogether(values ...interface{}) (string, error) {
+	if len(values) < 2 {
+		return "", errors.New("requiredTogether: needs at least two values")
+	}
+
+	set, unset := splitSet(values)
+	if len(set) != 0 && len(unset) != 0 {
+		return "", errors.Errorf("requiredTogether: all or none of the values must be set, set: %s; not set: %s",
+			describeArgs(set), describeArgs(unset))
+	}
+	return "", nil
+}
+
+// splitSet returns the indexes of values that are set and unset.
+func splitSet(values []interface{}) (set []int, unset []int) {
+	for i, value := range values {
+		if isEmptyValue(value) {
+			unset = append(unset, i)
+		} else {
+			set = append(set, i)
+		}
+	}
+	return set, unset
+}
+
+// describeArgs lists the 1-based argument positions in indexes. Values are
+// left out since they may be secrets and the error can end up in CI logs.
+func describeArgs(indexes []int) string {
+	args := make([]string, len(indexes))
+	for i, idx := range indexes {
+		args[i] = fmt.Sprintf("argument %d", idx+1)
+	}
+	return strings.Join(args, ", ")
+}